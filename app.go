@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"log/slog"
 
 	"github.com/devhou-se/sreetcode/internal/config"
@@ -15,6 +16,15 @@ func main() {
 		panic(err)
 	}
 
+	if s.Admin != nil {
+		go func() {
+			slog.Info(fmt.Sprintf("Starting admin server on %s", s.Admin.Addr))
+			if err := s.Admin.ListenAndServe(); err != nil {
+				panic(err)
+			}
+		}()
+	}
+
 	slog.Info("Starting server")
 	if err := s.ListenAndServe(); err != nil {
 		panic(err)
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+
+	"google.golang.org/grpc"
+
+	"github.com/devhou-se/sreetcode/internal/config"
+	pb "github.com/devhou-se/sreetcode/internal/gen"
+	"github.com/devhou-se/sreetcode/internal/ruleset"
+	"github.com/devhou-se/sreetcode/internal/sreeifier"
+)
+
+func main() {
+	cfg := config.LoadSreeifier()
+
+	if cfg.Ruleset != "" {
+		if err := ruleset.Use(cfg.Ruleset, cfg.RulesetReload); err != nil {
+			panic(err)
+		}
+	}
+
+	lis, err := net.Listen("tcp", ":"+cfg.Port)
+	if err != nil {
+		panic(err)
+	}
+
+	s := grpc.NewServer()
+	srv := sreeifier.NewServer()
+	pb.RegisterSreeificationServiceServer(s, srv)
+	pb.RegisterSreeificationServiceV2Server(s, srv)
+
+	slog.Info(fmt.Sprintf("Starting server on port %s", cfg.Port))
+	if err := s.Serve(lis); err != nil {
+		panic(err)
+	}
+}
@@ -4,15 +4,63 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
 	Port string
+	// AdminPort is the port metrics are served on, apart from the proxied sites. The admin server
+	// is disabled if it's empty.
+	AdminPort string
 
 	// Insecure is true if the server should use an insecure connection.
 	Insecure bool
 	// SreeifierServer is the address of the Sreeification gRPC server.
 	SreeifierServer string
+	// SreeifierBackend selects how pages are sreeified: "grpc", "local" or "noop".
+	SreeifierBackend string
+	// SreeifyTimeout is how long to wait for the Sreeification server to respond to a request.
+	SreeifyTimeout time.Duration
+	// SreeifyStreams is the number of streams the client keeps open to the Sreeification server.
+	SreeifyStreams int
+	// SreeifyFallback is what to serve when sreeifying a page fails: "original", "local" or "error".
+	SreeifyFallback string
+	// BreakerThreshold is the number of consecutive failures after which the gRPC sreeifier stops
+	// being called. Zero disables the circuit breaker.
+	BreakerThreshold int
+	// BreakerCooldown is how long the circuit stays open before the gRPC sreeifier is probed again.
+	BreakerCooldown time.Duration
+	// CacheStore is where sreeified pages are cached: "memory", "disk" or "none".
+	CacheStore string
+	// CacheSize is the maximum size in bytes of the cache, in memory or on disk.
+	CacheSize int
+	// CacheDir is the directory the disk cache is kept in.
+	CacheDir string
+	// CachePrivate allows responses marked Cache-Control: private to be cached, which Wikipedia marks
+	// all of its pages. They are revalidated every time they are served.
+	CachePrivate bool
+	// ForwardHeaders are the client request headers passed on to upstream.
+	ForwardHeaders []string
+	// HostMappings is a JSON file mapping sreeki hosts to upstream ones. The built-in mappings are
+	// used if it's empty.
+	HostMappings string
+	// Ruleset is a YAML or JSON file of the word replacement rules. The built-in rules are used if
+	// it's empty.
+	Ruleset string
+	// RulesetReload is how often the ruleset file is checked for changes. Zero disables reloading.
+	RulesetReload time.Duration
+}
+
+// SreeifierConfig is the configuration for the standalone Sreeification gRPC server.
+type SreeifierConfig struct {
+	Port string
+	// Ruleset is a YAML or JSON file of the word replacement rules.
+	Ruleset string
+	// RulesetReload is how often the ruleset file is checked for changes.
+	RulesetReload time.Duration
 }
 
 func envOrDefault(key, def string) string {
@@ -24,10 +72,68 @@ func envOrDefault(key, def string) string {
 	return v
 }
 
+func durationOrDefault(key string, def time.Duration) time.Duration {
+	v := envOrDefault(key, def.String())
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Invalid duration for %s: %s, using %s\n", key, v, def))
+		return def
+	}
+	return d
+}
+
+func intOrDefault(key string, def int) int {
+	v := envOrDefault(key, strconv.Itoa(def))
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Invalid integer for %s: %s, using %d\n", key, v, def))
+		return def
+	}
+	return i
+}
+
+func listOrDefault(key string, def []string) []string {
+	v := envOrDefault(key, strings.Join(def, ","))
+	var list []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 func Load() Config {
 	return Config{
-		Port:            envOrDefault("PORT", "8080"),
-		Insecure:        envOrDefault("INSECURE", "") != "false",
-		SreeifierServer: envOrDefault("SREEIFIER_SERVER", "sreeifier-vvgwyvu7bq-as.a.run.app:443"),
+		Port:             envOrDefault("PORT", "8080"),
+		AdminPort:        envOrDefault("ADMIN_PORT", ""),
+		Insecure:         envOrDefault("INSECURE", "") != "false",
+		SreeifierServer:  envOrDefault("SREEIFIER_SERVER", "sreeifier-vvgwyvu7bq-as.a.run.app:443"),
+		SreeifierBackend: envOrDefault("SREEIFIER_BACKEND", "grpc"),
+		SreeifyTimeout:   durationOrDefault("SREEIFY_TIMEOUT", 30*time.Second),
+		SreeifyStreams:   intOrDefault("SREEIFY_STREAMS", 4),
+		SreeifyFallback:  envOrDefault("SREEIFY_FALLBACK", "original"),
+		BreakerThreshold: intOrDefault("SREEIFY_BREAKER_THRESHOLD", 5),
+		BreakerCooldown:  durationOrDefault("SREEIFY_BREAKER_COOLDOWN", 30*time.Second),
+		CacheStore:       envOrDefault("CACHE_STORE", "memory"),
+		CacheSize:        intOrDefault("CACHE_SIZE", 256<<20),
+		CacheDir:         envOrDefault("CACHE_DIR", filepath.Join(os.TempDir(), "sreetcode-cache")),
+		CachePrivate:     envOrDefault("CACHE_PRIVATE", "true") == "true",
+		HostMappings:     envOrDefault("HOST_MAPPINGS", ""),
+		Ruleset:          envOrDefault("RULESET", ""),
+		RulesetReload:    durationOrDefault("RULESET_RELOAD", 30*time.Second),
+		ForwardHeaders: listOrDefault("FORWARD_HEADERS", []string{
+			"Accept", "Accept-Language", "Authorization", "Content-Type", "Cookie", "Origin", "Referer",
+			"User-Agent", "Range", "If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since",
+			"If-Range",
+		}),
+	}
+}
+
+func LoadSreeifier() SreeifierConfig {
+	return SreeifierConfig{
+		Port:          envOrDefault("PORT", "50051"),
+		Ruleset:       envOrDefault("RULESET", ""),
+		RulesetReload: durationOrDefault("RULESET_RELOAD", 30*time.Second),
 	}
 }
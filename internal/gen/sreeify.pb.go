@@ -20,6 +20,55 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type Error_Code int32
+
+const (
+	Error_UNKNOWN          Error_Code = 0
+	Error_INVALID_DOCUMENT Error_Code = 1
+	Error_INTERNAL         Error_Code = 2
+)
+
+// Enum value maps for Error_Code.
+var (
+	Error_Code_name = map[int32]string{
+		0: "UNKNOWN",
+		1: "INVALID_DOCUMENT",
+		2: "INTERNAL",
+	}
+	Error_Code_value = map[string]int32{
+		"UNKNOWN":          0,
+		"INVALID_DOCUMENT": 1,
+		"INTERNAL":         2,
+	}
+)
+
+func (x Error_Code) Enum() *Error_Code {
+	p := new(Error_Code)
+	*p = x
+	return p
+}
+
+func (x Error_Code) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Error_Code) Descriptor() protoreflect.EnumDescriptor {
+	return file_sreeify_proto_enumTypes[0].Descriptor()
+}
+
+func (Error_Code) Type() protoreflect.EnumType {
+	return &file_sreeify_proto_enumTypes[0]
+}
+
+func (x Error_Code) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Error_Code.Descriptor instead.
+func (Error_Code) EnumDescriptor() ([]byte, []int) {
+	return file_sreeify_proto_rawDescGZIP(), []int{2, 0}
+}
+
 type Payload struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -138,6 +187,69 @@ func (x *Ping) GetTime() int64 {
 	return 0
 }
 
+type Error struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id          string     `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Code        Error_Code `protobuf:"varint,2,opt,name=code,proto3,enum=sreeify.Error_Code" json:"code,omitempty"`
+	Description string     `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+}
+
+func (x *Error) Reset() {
+	*x = Error{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sreeify_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Error) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Error) ProtoMessage() {}
+
+func (x *Error) ProtoReflect() protoreflect.Message {
+	mi := &file_sreeify_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Error.ProtoReflect.Descriptor instead.
+func (*Error) Descriptor() ([]byte, []int) {
+	return file_sreeify_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Error) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Error) GetCode() Error_Code {
+	if x != nil {
+		return x.Code
+	}
+	return Error_UNKNOWN
+}
+
+func (x *Error) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
 type Sreequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -153,7 +265,7 @@ type Sreequest struct {
 func (x *Sreequest) Reset() {
 	*x = Sreequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sreeify_proto_msgTypes[2]
+		mi := &file_sreeify_proto_msgTypes[3]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -166,7 +278,7 @@ func (x *Sreequest) String() string {
 func (*Sreequest) ProtoMessage() {}
 
 func (x *Sreequest) ProtoReflect() protoreflect.Message {
-	mi := &file_sreeify_proto_msgTypes[2]
+	mi := &file_sreeify_proto_msgTypes[3]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -179,7 +291,7 @@ func (x *Sreequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Sreequest.ProtoReflect.Descriptor instead.
 func (*Sreequest) Descriptor() ([]byte, []int) {
-	return file_sreeify_proto_rawDescGZIP(), []int{2}
+	return file_sreeify_proto_rawDescGZIP(), []int{3}
 }
 
 func (m *Sreequest) GetData() isSreequest_Data {
@@ -228,13 +340,14 @@ type Sreesponse struct {
 	//
 	//	*Sreesponse_Payload
 	//	*Sreesponse_Ping
+	//	*Sreesponse_Error
 	Data isSreesponse_Data `protobuf_oneof:"data"`
 }
 
 func (x *Sreesponse) Reset() {
 	*x = Sreesponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_sreeify_proto_msgTypes[3]
+		mi := &file_sreeify_proto_msgTypes[4]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -247,7 +360,7 @@ func (x *Sreesponse) String() string {
 func (*Sreesponse) ProtoMessage() {}
 
 func (x *Sreesponse) ProtoReflect() protoreflect.Message {
-	mi := &file_sreeify_proto_msgTypes[3]
+	mi := &file_sreeify_proto_msgTypes[4]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -260,7 +373,7 @@ func (x *Sreesponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Sreesponse.ProtoReflect.Descriptor instead.
 func (*Sreesponse) Descriptor() ([]byte, []int) {
-	return file_sreeify_proto_rawDescGZIP(), []int{3}
+	return file_sreeify_proto_rawDescGZIP(), []int{4}
 }
 
 func (m *Sreesponse) GetData() isSreesponse_Data {
@@ -284,6 +397,13 @@ func (x *Sreesponse) GetPing() *Ping {
 	return nil
 }
 
+func (x *Sreesponse) GetError() *Error {
+	if x, ok := x.GetData().(*Sreesponse_Error); ok {
+		return x.Error
+	}
+	return nil
+}
+
 type isSreesponse_Data interface {
 	isSreesponse_Data()
 }
@@ -296,10 +416,189 @@ type Sreesponse_Ping struct {
 	Ping *Ping `protobuf:"bytes,2,opt,name=ping,proto3,oneof"`
 }
 
+type Sreesponse_Error struct {
+	Error *Error `protobuf:"bytes,3,opt,name=error,proto3,oneof"`
+}
+
 func (*Sreesponse_Payload) isSreesponse_Data() {}
 
 func (*Sreesponse_Ping) isSreesponse_Data() {}
 
+func (*Sreesponse_Error) isSreesponse_Data() {}
+
+type Metadata struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ContentType string `protobuf:"bytes,1,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	Charset     string `protobuf:"bytes,2,opt,name=charset,proto3" json:"charset,omitempty"`
+	SourceUrl   string `protobuf:"bytes,3,opt,name=source_url,json=sourceUrl,proto3" json:"source_url,omitempty"`
+	Language    string `protobuf:"bytes,4,opt,name=language,proto3" json:"language,omitempty"`
+}
+
+func (x *Metadata) Reset() {
+	*x = Metadata{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sreeify_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Metadata) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Metadata) ProtoMessage() {}
+
+func (x *Metadata) ProtoReflect() protoreflect.Message {
+	mi := &file_sreeify_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Metadata.ProtoReflect.Descriptor instead.
+func (*Metadata) Descriptor() ([]byte, []int) {
+	return file_sreeify_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Metadata) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *Metadata) GetCharset() string {
+	if x != nil {
+		return x.Charset
+	}
+	return ""
+}
+
+func (x *Metadata) GetSourceUrl() string {
+	if x != nil {
+		return x.SourceUrl
+	}
+	return ""
+}
+
+func (x *Metadata) GetLanguage() string {
+	if x != nil {
+		return x.Language
+	}
+	return ""
+}
+
+type Document struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Metadata *Metadata `protobuf:"bytes,1,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	Data     []byte    `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+}
+
+func (x *Document) Reset() {
+	*x = Document{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sreeify_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Document) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Document) ProtoMessage() {}
+
+func (x *Document) ProtoReflect() protoreflect.Message {
+	mi := &file_sreeify_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Document.ProtoReflect.Descriptor instead.
+func (*Document) Descriptor() ([]byte, []int) {
+	return file_sreeify_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Document) GetMetadata() *Metadata {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *Document) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type DocumentChunk struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Data []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+}
+
+func (x *DocumentChunk) Reset() {
+	*x = DocumentChunk{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_sreeify_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DocumentChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DocumentChunk) ProtoMessage() {}
+
+func (x *DocumentChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_sreeify_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DocumentChunk.ProtoReflect.Descriptor instead.
+func (*DocumentChunk) Descriptor() ([]byte, []int) {
+	return file_sreeify_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *DocumentChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
 var File_sreeify_proto protoreflect.FileDescriptor
 
 var file_sreeify_proto_rawDesc = []byte{
@@ -312,34 +611,72 @@ var file_sreeify_proto_rawDesc = []byte{
 	0x74, 0x61, 0x6c, 0x50, 0x61, 0x72, 0x74, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61,
 	0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x22, 0x1a, 0x0a, 0x04,
 	0x50, 0x69, 0x6e, 0x67, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01,
-	0x28, 0x03, 0x52, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x22, 0x66, 0x0a, 0x09, 0x53, 0x72, 0x65, 0x65,
-	0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x2c, 0x0a, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64,
-	0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x73, 0x72, 0x65, 0x65, 0x69, 0x66, 0x79,
-	0x2e, 0x50, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x48, 0x00, 0x52, 0x07, 0x70, 0x61, 0x79, 0x6c,
-	0x6f, 0x61, 0x64, 0x12, 0x23, 0x0a, 0x04, 0x70, 0x69, 0x6e, 0x67, 0x18, 0x02, 0x20, 0x01, 0x28,
-	0x0b, 0x32, 0x0d, 0x2e, 0x73, 0x72, 0x65, 0x65, 0x69, 0x66, 0x79, 0x2e, 0x50, 0x69, 0x6e, 0x67,
-	0x48, 0x00, 0x52, 0x04, 0x70, 0x69, 0x6e, 0x67, 0x42, 0x06, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61,
-	0x22, 0x67, 0x0a, 0x0a, 0x53, 0x72, 0x65, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2c,
-	0x0a, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32,
-	0x10, 0x2e, 0x73, 0x72, 0x65, 0x65, 0x69, 0x66, 0x79, 0x2e, 0x50, 0x61, 0x79, 0x6c, 0x6f, 0x61,
-	0x64, 0x48, 0x00, 0x52, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x12, 0x23, 0x0a, 0x04,
-	0x70, 0x69, 0x6e, 0x67, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0d, 0x2e, 0x73, 0x72, 0x65,
-	0x65, 0x69, 0x66, 0x79, 0x2e, 0x50, 0x69, 0x6e, 0x67, 0x48, 0x00, 0x52, 0x04, 0x70, 0x69, 0x6e,
-	0x67, 0x42, 0x06, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x32, 0x50, 0x0a, 0x14, 0x53, 0x72, 0x65,
+	0x28, 0x03, 0x52, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x22, 0x9b, 0x01, 0x0a, 0x05, 0x45, 0x72, 0x72,
+	0x6f, 0x72, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02,
+	0x69, 0x64, 0x12, 0x27, 0x0a, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e,
+	0x32, 0x13, 0x2e, 0x73, 0x72, 0x65, 0x65, 0x69, 0x66, 0x79, 0x2e, 0x45, 0x72, 0x72, 0x6f, 0x72,
+	0x2e, 0x43, 0x6f, 0x64, 0x65, 0x52, 0x04, 0x63, 0x6f, 0x64, 0x65, 0x12, 0x20, 0x0a, 0x0b, 0x64,
+	0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0x37, 0x0a,
+	0x04, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x0b, 0x0a, 0x07, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e,
+	0x10, 0x00, 0x12, 0x14, 0x0a, 0x10, 0x49, 0x4e, 0x56, 0x41, 0x4c, 0x49, 0x44, 0x5f, 0x44, 0x4f,
+	0x43, 0x55, 0x4d, 0x45, 0x4e, 0x54, 0x10, 0x01, 0x12, 0x0c, 0x0a, 0x08, 0x49, 0x4e, 0x54, 0x45,
+	0x52, 0x4e, 0x41, 0x4c, 0x10, 0x02, 0x22, 0x66, 0x0a, 0x09, 0x53, 0x72, 0x65, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x12, 0x2c, 0x0a, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x73, 0x72, 0x65, 0x65, 0x69, 0x66, 0x79, 0x2e, 0x50,
+	0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x48, 0x00, 0x52, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61,
+	0x64, 0x12, 0x23, 0x0a, 0x04, 0x70, 0x69, 0x6e, 0x67, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x0d, 0x2e, 0x73, 0x72, 0x65, 0x65, 0x69, 0x66, 0x79, 0x2e, 0x50, 0x69, 0x6e, 0x67, 0x48, 0x00,
+	0x52, 0x04, 0x70, 0x69, 0x6e, 0x67, 0x42, 0x06, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x22, 0x8f,
+	0x01, 0x0a, 0x0a, 0x53, 0x72, 0x65, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2c, 0x0a,
+	0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10,
+	0x2e, 0x73, 0x72, 0x65, 0x65, 0x69, 0x66, 0x79, 0x2e, 0x50, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64,
+	0x48, 0x00, 0x52, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x12, 0x23, 0x0a, 0x04, 0x70,
+	0x69, 0x6e, 0x67, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0d, 0x2e, 0x73, 0x72, 0x65, 0x65,
+	0x69, 0x66, 0x79, 0x2e, 0x50, 0x69, 0x6e, 0x67, 0x48, 0x00, 0x52, 0x04, 0x70, 0x69, 0x6e, 0x67,
+	0x12, 0x26, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x0e, 0x2e, 0x73, 0x72, 0x65, 0x65, 0x69, 0x66, 0x79, 0x2e, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x48,
+	0x00, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x42, 0x06, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61,
+	0x22, 0x82, 0x01, 0x0a, 0x08, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x12, 0x21, 0x0a,
+	0x0c, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x0b, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x54, 0x79, 0x70, 0x65,
+	0x12, 0x18, 0x0a, 0x07, 0x63, 0x68, 0x61, 0x72, 0x73, 0x65, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x07, 0x63, 0x68, 0x61, 0x72, 0x73, 0x65, 0x74, 0x12, 0x1d, 0x0a, 0x0a, 0x73, 0x6f,
+	0x75, 0x72, 0x63, 0x65, 0x5f, 0x75, 0x72, 0x6c, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09,
+	0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x55, 0x72, 0x6c, 0x12, 0x1a, 0x0a, 0x08, 0x6c, 0x61, 0x6e,
+	0x67, 0x75, 0x61, 0x67, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x6c, 0x61, 0x6e,
+	0x67, 0x75, 0x61, 0x67, 0x65, 0x22, 0x4d, 0x0a, 0x08, 0x44, 0x6f, 0x63, 0x75, 0x6d, 0x65, 0x6e,
+	0x74, 0x12, 0x2d, 0x0a, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x73, 0x72, 0x65, 0x65, 0x69, 0x66, 0x79, 0x2e, 0x4d, 0x65,
+	0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x52, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61,
+	0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04,
+	0x64, 0x61, 0x74, 0x61, 0x22, 0x23, 0x0a, 0x0d, 0x44, 0x6f, 0x63, 0x75, 0x6d, 0x65, 0x6e, 0x74,
+	0x43, 0x68, 0x75, 0x6e, 0x6b, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x32, 0x50, 0x0a, 0x14, 0x53, 0x72, 0x65,
 	0x65, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63,
 	0x65, 0x12, 0x38, 0x0a, 0x07, 0x53, 0x72, 0x65, 0x65, 0x69, 0x66, 0x79, 0x12, 0x12, 0x2e, 0x73,
 	0x72, 0x65, 0x65, 0x69, 0x66, 0x79, 0x2e, 0x53, 0x72, 0x65, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
 	0x1a, 0x13, 0x2e, 0x73, 0x72, 0x65, 0x65, 0x69, 0x66, 0x79, 0x2e, 0x53, 0x72, 0x65, 0x65, 0x73,
-	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x28, 0x01, 0x30, 0x01, 0x42, 0x81, 0x01, 0x0a, 0x0b,
-	0x63, 0x6f, 0x6d, 0x2e, 0x73, 0x72, 0x65, 0x65, 0x69, 0x66, 0x79, 0x42, 0x0c, 0x53, 0x72, 0x65,
-	0x65, 0x69, 0x66, 0x79, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x50, 0x01, 0x5a, 0x28, 0x67, 0x69, 0x74,
-	0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x64, 0x65, 0x76, 0x68, 0x6f, 0x75, 0x2d, 0x73,
-	0x65, 0x2f, 0x73, 0x72, 0x65, 0x65, 0x74, 0x63, 0x6f, 0x64, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74,
-	0x6f, 0x2f, 0x67, 0x65, 0x6e, 0xa2, 0x02, 0x03, 0x53, 0x58, 0x58, 0xaa, 0x02, 0x07, 0x53, 0x72,
-	0x65, 0x65, 0x69, 0x66, 0x79, 0xca, 0x02, 0x07, 0x53, 0x72, 0x65, 0x65, 0x69, 0x66, 0x79, 0xe2,
-	0x02, 0x13, 0x53, 0x72, 0x65, 0x65, 0x69, 0x66, 0x79, 0x5c, 0x47, 0x50, 0x42, 0x4d, 0x65, 0x74,
-	0x61, 0x64, 0x61, 0x74, 0x61, 0xea, 0x02, 0x07, 0x53, 0x72, 0x65, 0x65, 0x69, 0x66, 0x79, 0x62,
-	0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x28, 0x01, 0x30, 0x01, 0x32, 0x9b, 0x01, 0x0a, 0x16,
+	0x53, 0x72, 0x65, 0x65, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x53, 0x65, 0x72,
+	0x76, 0x69, 0x63, 0x65, 0x56, 0x32, 0x12, 0x39, 0x0a, 0x0f, 0x53, 0x72, 0x65, 0x65, 0x69, 0x66,
+	0x79, 0x44, 0x6f, 0x63, 0x75, 0x6d, 0x65, 0x6e, 0x74, 0x12, 0x11, 0x2e, 0x73, 0x72, 0x65, 0x65,
+	0x69, 0x66, 0x79, 0x2e, 0x44, 0x6f, 0x63, 0x75, 0x6d, 0x65, 0x6e, 0x74, 0x1a, 0x11, 0x2e, 0x73,
+	0x72, 0x65, 0x65, 0x69, 0x66, 0x79, 0x2e, 0x44, 0x6f, 0x63, 0x75, 0x6d, 0x65, 0x6e, 0x74, 0x22,
+	0x00, 0x12, 0x46, 0x0a, 0x15, 0x53, 0x72, 0x65, 0x65, 0x69, 0x66, 0x79, 0x44, 0x6f, 0x63, 0x75,
+	0x6d, 0x65, 0x6e, 0x74, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x11, 0x2e, 0x73, 0x72, 0x65,
+	0x65, 0x69, 0x66, 0x79, 0x2e, 0x44, 0x6f, 0x63, 0x75, 0x6d, 0x65, 0x6e, 0x74, 0x1a, 0x16, 0x2e,
+	0x73, 0x72, 0x65, 0x65, 0x69, 0x66, 0x79, 0x2e, 0x44, 0x6f, 0x63, 0x75, 0x6d, 0x65, 0x6e, 0x74,
+	0x43, 0x68, 0x75, 0x6e, 0x6b, 0x22, 0x00, 0x30, 0x01, 0x42, 0x81, 0x01, 0x0a, 0x0b, 0x63, 0x6f,
+	0x6d, 0x2e, 0x73, 0x72, 0x65, 0x65, 0x69, 0x66, 0x79, 0x42, 0x0c, 0x53, 0x72, 0x65, 0x65, 0x69,
+	0x66, 0x79, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x50, 0x01, 0x5a, 0x28, 0x67, 0x69, 0x74, 0x68, 0x75,
+	0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x64, 0x65, 0x76, 0x68, 0x6f, 0x75, 0x2d, 0x73, 0x65, 0x2f,
+	0x73, 0x72, 0x65, 0x65, 0x74, 0x63, 0x6f, 0x64, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f,
+	0x67, 0x65, 0x6e, 0xa2, 0x02, 0x03, 0x53, 0x58, 0x58, 0xaa, 0x02, 0x07, 0x53, 0x72, 0x65, 0x65,
+	0x69, 0x66, 0x79, 0xca, 0x02, 0x07, 0x53, 0x72, 0x65, 0x65, 0x69, 0x66, 0x79, 0xe2, 0x02, 0x13,
+	0x53, 0x72, 0x65, 0x65, 0x69, 0x66, 0x79, 0x5c, 0x47, 0x50, 0x42, 0x4d, 0x65, 0x74, 0x61, 0x64,
+	0x61, 0x74, 0x61, 0xea, 0x02, 0x07, 0x53, 0x72, 0x65, 0x65, 0x69, 0x66, 0x79, 0x62, 0x06, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -354,25 +691,38 @@ func file_sreeify_proto_rawDescGZIP() []byte {
 	return file_sreeify_proto_rawDescData
 }
 
-var file_sreeify_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_sreeify_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_sreeify_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
 var file_sreeify_proto_goTypes = []interface{}{
-	(*Payload)(nil),    // 0: sreeify.Payload
-	(*Ping)(nil),       // 1: sreeify.Ping
-	(*Sreequest)(nil),  // 2: sreeify.Sreequest
-	(*Sreesponse)(nil), // 3: sreeify.Sreesponse
+	(Error_Code)(0),       // 0: sreeify.Error.Code
+	(*Payload)(nil),       // 1: sreeify.Payload
+	(*Ping)(nil),          // 2: sreeify.Ping
+	(*Error)(nil),         // 3: sreeify.Error
+	(*Sreequest)(nil),     // 4: sreeify.Sreequest
+	(*Sreesponse)(nil),    // 5: sreeify.Sreesponse
+	(*Metadata)(nil),      // 6: sreeify.Metadata
+	(*Document)(nil),      // 7: sreeify.Document
+	(*DocumentChunk)(nil), // 8: sreeify.DocumentChunk
 }
 var file_sreeify_proto_depIdxs = []int32{
-	0, // 0: sreeify.Sreequest.payload:type_name -> sreeify.Payload
-	1, // 1: sreeify.Sreequest.ping:type_name -> sreeify.Ping
-	0, // 2: sreeify.Sreesponse.payload:type_name -> sreeify.Payload
-	1, // 3: sreeify.Sreesponse.ping:type_name -> sreeify.Ping
-	2, // 4: sreeify.SreeificationService.Sreeify:input_type -> sreeify.Sreequest
-	3, // 5: sreeify.SreeificationService.Sreeify:output_type -> sreeify.Sreesponse
-	5, // [5:6] is the sub-list for method output_type
-	4, // [4:5] is the sub-list for method input_type
-	4, // [4:4] is the sub-list for extension type_name
-	4, // [4:4] is the sub-list for extension extendee
-	0, // [0:4] is the sub-list for field type_name
+	0,  // 0: sreeify.Error.code:type_name -> sreeify.Error.Code
+	1,  // 1: sreeify.Sreequest.payload:type_name -> sreeify.Payload
+	2,  // 2: sreeify.Sreequest.ping:type_name -> sreeify.Ping
+	1,  // 3: sreeify.Sreesponse.payload:type_name -> sreeify.Payload
+	2,  // 4: sreeify.Sreesponse.ping:type_name -> sreeify.Ping
+	3,  // 5: sreeify.Sreesponse.error:type_name -> sreeify.Error
+	6,  // 6: sreeify.Document.metadata:type_name -> sreeify.Metadata
+	4,  // 7: sreeify.SreeificationService.Sreeify:input_type -> sreeify.Sreequest
+	7,  // 8: sreeify.SreeificationServiceV2.SreeifyDocument:input_type -> sreeify.Document
+	7,  // 9: sreeify.SreeificationServiceV2.SreeifyDocumentStream:input_type -> sreeify.Document
+	5,  // 10: sreeify.SreeificationService.Sreeify:output_type -> sreeify.Sreesponse
+	7,  // 11: sreeify.SreeificationServiceV2.SreeifyDocument:output_type -> sreeify.Document
+	8,  // 12: sreeify.SreeificationServiceV2.SreeifyDocumentStream:output_type -> sreeify.DocumentChunk
+	10, // [10:13] is the sub-list for method output_type
+	7,  // [7:10] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
 }
 
 func init() { file_sreeify_proto_init() }
@@ -406,7 +756,7 @@ func file_sreeify_proto_init() {
 			}
 		}
 		file_sreeify_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Sreequest); i {
+			switch v := v.(*Error); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -418,6 +768,18 @@ func file_sreeify_proto_init() {
 			}
 		}
 		file_sreeify_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Sreequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sreeify_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*Sreesponse); i {
 			case 0:
 				return &v.state
@@ -429,27 +791,65 @@ func file_sreeify_proto_init() {
 				return nil
 			}
 		}
+		file_sreeify_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Metadata); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sreeify_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Document); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_sreeify_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DocumentChunk); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
-	file_sreeify_proto_msgTypes[2].OneofWrappers = []interface{}{
+	file_sreeify_proto_msgTypes[3].OneofWrappers = []interface{}{
 		(*Sreequest_Payload)(nil),
 		(*Sreequest_Ping)(nil),
 	}
-	file_sreeify_proto_msgTypes[3].OneofWrappers = []interface{}{
+	file_sreeify_proto_msgTypes[4].OneofWrappers = []interface{}{
 		(*Sreesponse_Payload)(nil),
 		(*Sreesponse_Ping)(nil),
+		(*Sreesponse_Error)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_sreeify_proto_rawDesc,
-			NumEnums:      0,
-			NumMessages:   4,
+			NumEnums:      1,
+			NumMessages:   8,
 			NumExtensions: 0,
-			NumServices:   1,
+			NumServices:   2,
 		},
 		GoTypes:           file_sreeify_proto_goTypes,
 		DependencyIndexes: file_sreeify_proto_depIdxs,
+		EnumInfos:         file_sreeify_proto_enumTypes,
 		MessageInfos:      file_sreeify_proto_msgTypes,
 	}.Build()
 	File_sreeify_proto = out.File
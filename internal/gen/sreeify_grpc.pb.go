@@ -139,3 +139,159 @@ var SreeificationService_ServiceDesc = grpc.ServiceDesc{
 	},
 	Metadata: "sreeify.proto",
 }
+
+const (
+	SreeificationServiceV2_SreeifyDocument_FullMethodName       = "/sreeify.SreeificationServiceV2/SreeifyDocument"
+	SreeificationServiceV2_SreeifyDocumentStream_FullMethodName = "/sreeify.SreeificationServiceV2/SreeifyDocumentStream"
+)
+
+// SreeificationServiceV2Client is the client API for SreeificationServiceV2 service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SreeificationServiceV2Client interface {
+	SreeifyDocument(ctx context.Context, in *Document, opts ...grpc.CallOption) (*Document, error)
+	SreeifyDocumentStream(ctx context.Context, in *Document, opts ...grpc.CallOption) (SreeificationServiceV2_SreeifyDocumentStreamClient, error)
+}
+
+type sreeificationServiceV2Client struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSreeificationServiceV2Client(cc grpc.ClientConnInterface) SreeificationServiceV2Client {
+	return &sreeificationServiceV2Client{cc}
+}
+
+func (c *sreeificationServiceV2Client) SreeifyDocument(ctx context.Context, in *Document, opts ...grpc.CallOption) (*Document, error) {
+	out := new(Document)
+	err := c.cc.Invoke(ctx, SreeificationServiceV2_SreeifyDocument_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sreeificationServiceV2Client) SreeifyDocumentStream(ctx context.Context, in *Document, opts ...grpc.CallOption) (SreeificationServiceV2_SreeifyDocumentStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &SreeificationServiceV2_ServiceDesc.Streams[0], SreeificationServiceV2_SreeifyDocumentStream_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &sreeificationServiceV2SreeifyDocumentStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type SreeificationServiceV2_SreeifyDocumentStreamClient interface {
+	Recv() (*DocumentChunk, error)
+	grpc.ClientStream
+}
+
+type sreeificationServiceV2SreeifyDocumentStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *sreeificationServiceV2SreeifyDocumentStreamClient) Recv() (*DocumentChunk, error) {
+	m := new(DocumentChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SreeificationServiceV2Server is the server API for SreeificationServiceV2 service.
+// All implementations must embed UnimplementedSreeificationServiceV2Server
+// for forward compatibility
+type SreeificationServiceV2Server interface {
+	SreeifyDocument(context.Context, *Document) (*Document, error)
+	SreeifyDocumentStream(*Document, SreeificationServiceV2_SreeifyDocumentStreamServer) error
+	mustEmbedUnimplementedSreeificationServiceV2Server()
+}
+
+// UnimplementedSreeificationServiceV2Server must be embedded to have forward compatible implementations.
+type UnimplementedSreeificationServiceV2Server struct {
+}
+
+func (UnimplementedSreeificationServiceV2Server) SreeifyDocument(context.Context, *Document) (*Document, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SreeifyDocument not implemented")
+}
+func (UnimplementedSreeificationServiceV2Server) SreeifyDocumentStream(*Document, SreeificationServiceV2_SreeifyDocumentStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method SreeifyDocumentStream not implemented")
+}
+func (UnimplementedSreeificationServiceV2Server) mustEmbedUnimplementedSreeificationServiceV2Server() {
+}
+
+// UnsafeSreeificationServiceV2Server may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SreeificationServiceV2Server will
+// result in compilation errors.
+type UnsafeSreeificationServiceV2Server interface {
+	mustEmbedUnimplementedSreeificationServiceV2Server()
+}
+
+func RegisterSreeificationServiceV2Server(s grpc.ServiceRegistrar, srv SreeificationServiceV2Server) {
+	s.RegisterService(&SreeificationServiceV2_ServiceDesc, srv)
+}
+
+func _SreeificationServiceV2_SreeifyDocument_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Document)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SreeificationServiceV2Server).SreeifyDocument(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SreeificationServiceV2_SreeifyDocument_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SreeificationServiceV2Server).SreeifyDocument(ctx, req.(*Document))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SreeificationServiceV2_SreeifyDocumentStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Document)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SreeificationServiceV2Server).SreeifyDocumentStream(m, &sreeificationServiceV2SreeifyDocumentStreamServer{stream})
+}
+
+type SreeificationServiceV2_SreeifyDocumentStreamServer interface {
+	Send(*DocumentChunk) error
+	grpc.ServerStream
+}
+
+type sreeificationServiceV2SreeifyDocumentStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *sreeificationServiceV2SreeifyDocumentStreamServer) Send(m *DocumentChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// SreeificationServiceV2_ServiceDesc is the grpc.ServiceDesc for SreeificationServiceV2 service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SreeificationServiceV2_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "sreeify.SreeificationServiceV2",
+	HandlerType: (*SreeificationServiceV2Server)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SreeifyDocument",
+			Handler:    _SreeificationServiceV2_SreeifyDocument_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SreeifyDocumentStream",
+			Handler:       _SreeificationServiceV2_SreeifyDocumentStream_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "sreeify.proto",
+}
@@ -0,0 +1,50 @@
+// Package grpctest runs gRPC servers on in-memory connections for tests.
+package grpctest
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const bufSize = 1024 * 1024
+
+// Listen starts a gRPC server with the services that register adds on an in-memory listener,
+// which is stopped when the test ends.
+func Listen(t testing.TB, register func(*grpc.Server)) *bufconn.Listener {
+	t.Helper()
+
+	lis := bufconn.Listen(bufSize)
+	s := grpc.NewServer()
+	register(s)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	return lis
+}
+
+// Dial connects to lis, closing the connection when the test ends.
+func Dial(t testing.TB, lis *bufconn.Listener) *grpc.ClientConn {
+	t.Helper()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// Serve starts a gRPC server with the services that register adds and returns a connection to it.
+func Serve(t testing.TB, register func(*grpc.Server)) *grpc.ClientConn {
+	t.Helper()
+	return Dial(t, Listen(t, register))
+}
@@ -0,0 +1,205 @@
+// Package hostmap maps the hosts and paths of the sreeki sites to those of the upstream sites they
+// proxy, and back again.
+package hostmap
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+)
+
+//go:embed hosts.json
+var defaultTable []byte
+
+// Table is an ordered list of mappings. The first mapping that matches a host is used, in either
+// direction.
+type Table struct {
+	Mappings []*Mapping `json:"mappings"`
+}
+
+// Mapping maps the hosts of a sreeki site to the hosts of its upstream site.
+type Mapping struct {
+	// Name identifies the mapping in logs.
+	Name string `json:"name"`
+	// Source is the pattern of the sreeki hosts, such as "*.sreekipedia.org". A "*" at the start
+	// matches one or more labels, which are carried over to the other host.
+	Source string `json:"source"`
+	// Upstream is the pattern of the upstream hosts, such as "*.wikipedia.org".
+	Upstream string `json:"upstream"`
+	// Paths are the path prefixes that differ between the sites, such as /sreeki/ for /wiki/.
+	Paths []PathRewrite `json:"paths"`
+
+	// Scheme is the scheme used to reach upstream. It defaults to https.
+	Scheme string `json:"scheme,omitempty"`
+	// Sreeify controls whether pages are sreeified. It defaults to true.
+	Sreeify *bool `json:"sreeify,omitempty"`
+}
+
+// PathRewrite maps a path prefix on the sreeki site to one upstream.
+type PathRewrite struct {
+	Source   string `json:"source"`
+	Upstream string `json:"upstream"`
+}
+
+// Default returns the built-in table, covering Wikipedia and its sister projects.
+func Default() *Table {
+	t, err := parse(defaultTable)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Load reads a table from a JSON file in the format of hosts.json.
+func Load(path string) (*Table, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading host mappings: %w", err)
+	}
+	return parse(data)
+}
+
+func parse(data []byte) (*Table, error) {
+	var t Table
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("parsing host mappings: %w", err)
+	}
+	for _, m := range t.Mappings {
+		if err := m.validate(); err != nil {
+			return nil, fmt.Errorf("host mapping %q: %w", m.Name, err)
+		}
+	}
+	return &t, nil
+}
+
+func (m *Mapping) validate() error {
+	if m.Source == "" || m.Upstream == "" {
+		return fmt.Errorf("source and upstream hosts are required")
+	}
+	if strings.HasPrefix(m.Source, "*.") != strings.HasPrefix(m.Upstream, "*.") {
+		return fmt.Errorf("source and upstream hosts must both or neither start with a wildcard")
+	}
+	if strings.Contains(strings.TrimPrefix(m.Source, "*."), "*") || strings.Contains(strings.TrimPrefix(m.Upstream, "*."), "*") {
+		return fmt.Errorf("a wildcard may only be the first label of a host")
+	}
+	for _, p := range m.Paths {
+		if !strings.HasPrefix(p.Source, "/") || !strings.HasPrefix(p.Upstream, "/") {
+			return fmt.Errorf("path prefixes must start with /")
+		}
+	}
+	return nil
+}
+
+// UpstreamScheme returns the scheme used to reach upstream.
+func (m *Mapping) UpstreamScheme() string {
+	if m.Scheme == "" {
+		return "https"
+	}
+	return m.Scheme
+}
+
+// Sreeifies reports whether the mapping's pages are sreeified.
+func (m *Mapping) Sreeifies() bool {
+	return m.Sreeify == nil || *m.Sreeify
+}
+
+// Upstream returns the upstream host for a sreeki host, and the mapping that matched it. Any port
+// on host is ignored.
+func (t *Table) Upstream(host string) (string, *Mapping, bool) {
+	host = hostname(host)
+	for _, m := range t.Mappings {
+		if h, ok := translate(host, m.Source, m.Upstream); ok {
+			return h, m, true
+		}
+	}
+	return "", nil, false
+}
+
+// Sreeki returns the sreeki host for an upstream host, and the mapping that matched it. It is the
+// reverse of Upstream.
+func (t *Table) Sreeki(host string) (string, *Mapping, bool) {
+	host = hostname(host)
+	for _, m := range t.Mappings {
+		if h, ok := translate(host, m.Upstream, m.Source); ok {
+			return h, m, true
+		}
+	}
+	return "", nil, false
+}
+
+// SreekiDomain returns the sreeki cookie domain for an upstream one, such as sreekipedia.org for
+// wikipedia.org.
+func (t *Table) SreekiDomain(domain string) (string, bool) {
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+	for _, m := range t.Mappings {
+		if m.Upstream == "*."+domain {
+			return strings.TrimPrefix(m.Source, "*."), true
+		}
+	}
+	h, _, ok := t.Sreeki(domain)
+	return h, ok
+}
+
+// UpstreamPath rewrites the path of u from the sreeki site to the upstream one, keeping its
+// escaping. It reports whether the path was changed.
+func (m *Mapping) UpstreamPath(u *url.URL) bool {
+	for _, p := range m.Paths {
+		if p.Source != p.Upstream && ReplacePathPrefix(u, p.Source, p.Upstream) {
+			return true
+		}
+	}
+	return false
+}
+
+// SreekiPath rewrites the path of u from the upstream site to the sreeki one. It is the reverse of
+// UpstreamPath.
+func (m *Mapping) SreekiPath(u *url.URL) bool {
+	for _, p := range m.Paths {
+		if p.Source != p.Upstream && ReplacePathPrefix(u, p.Upstream, p.Source) {
+			return true
+		}
+	}
+	return false
+}
+
+// translate matches host against the pattern from, and returns the matching host of pattern to.
+func translate(host, from, to string) (string, bool) {
+	suffix, wildcard := strings.CutPrefix(from, "*")
+	if !wildcard {
+		return to, host == from
+	}
+
+	labels, ok := strings.CutSuffix(host, suffix)
+	if !ok || labels == "" {
+		return "", false
+	}
+	return labels + strings.TrimPrefix(to, "*"), true
+}
+
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
+
+// ReplacePathPrefix replaces the prefix of u's path, keeping the path's escaping. It reports whether
+// the path had the prefix.
+func ReplacePathPrefix(u *url.URL, from, to string) bool {
+	p := u.EscapedPath()
+	if !strings.HasPrefix(p, from) {
+		return false
+	}
+	p = to + strings.TrimPrefix(p, from)
+
+	path, err := url.PathUnescape(p)
+	if err != nil {
+		return false
+	}
+	u.Path, u.RawPath = path, p
+	return true
+}
@@ -0,0 +1,116 @@
+package hostmap
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestDefaultRoundTrip(t *testing.T) {
+	tests := []struct {
+		host     string
+		mapping  string
+		upstream string
+		// sreeki is the host Sreeki maps upstream back to. It is host without its port, except
+		// where an earlier mapping claims the upstream host.
+		sreeki string
+	}{
+		{"en.sreekipedia.org", "wikipedia", "en.wikipedia.org", "en.sreekipedia.org"},
+		{"EN.m.Sreekipedia.org", "wikipedia", "en.m.wikipedia.org", "en.m.sreekipedia.org"},
+		{"fr.sreektionary.org", "wiktionary", "fr.wiktionary.org", "fr.sreektionary.org"},
+		{"en.sreekiquote.org", "wikiquote", "en.wikiquote.org", "en.sreekiquote.org"},
+		{"de.sreekinews.org", "wikinews", "de.wikinews.org", "de.sreekinews.org"},
+		{"en.sreekiversity.org:443", "wikiversity", "en.wikiversity.org", "en.sreekiversity.org"},
+		{"commons.sreekimedia.org", "commons", "commons.wikimedia.org", "commons.sreekimedia.org"},
+		{"localhost:8080", "localhost", "en.wikipedia.org", "en.sreekipedia.org"},
+	}
+
+	table := Default()
+	covered := make(map[string]bool)
+	for _, tt := range tests {
+		up, m, ok := table.Upstream(tt.host)
+		if !ok || up != tt.upstream || m.Name != tt.mapping {
+			t.Errorf("Upstream(%q) = %q, %v, %t; want %q by mapping %q", tt.host, up, m, ok, tt.upstream, tt.mapping)
+			continue
+		}
+		covered[m.Name] = true
+
+		sreeki, _, ok := table.Sreeki(up)
+		if !ok || sreeki != tt.sreeki {
+			t.Errorf("Sreeki(%q) = %q, %t; want %q", up, sreeki, ok, tt.sreeki)
+		}
+	}
+
+	for _, m := range table.Mappings {
+		if !covered[m.Name] {
+			t.Errorf("mapping %q isn't covered", m.Name)
+		}
+	}
+}
+
+func TestDefaultUnmapped(t *testing.T) {
+	table := Default()
+	for _, host := range []string{"sreekipedia.org", "example.com", "en.wikipedia.org"} {
+		if up, _, ok := table.Upstream(host); ok {
+			t.Errorf("Upstream(%q) = %q, want no mapping", host, up)
+		}
+	}
+	for _, host := range []string{"wikipedia.org", "example.com"} {
+		if sreeki, _, ok := table.Sreeki(host); ok {
+			t.Errorf("Sreeki(%q) = %q, want no mapping", host, sreeki)
+		}
+	}
+}
+
+func TestSreekiDomain(t *testing.T) {
+	tests := []struct {
+		domain string
+		want   string
+		ok     bool
+	}{
+		{".wikipedia.org", "sreekipedia.org", true},
+		{"wikipedia.org", "sreekipedia.org", true},
+		{".WIKTIONARY.org", "sreektionary.org", true},
+		{"en.wikipedia.org", "en.sreekipedia.org", true},
+		{"commons.wikimedia.org", "commons.sreekimedia.org", true},
+		{".wikimedia.org", "", false},
+		{".example.com", "", false},
+	}
+
+	table := Default()
+	for _, tt := range tests {
+		got, ok := table.SreekiDomain(tt.domain)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("SreekiDomain(%q) = %q, %t; want %q, %t", tt.domain, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPathsInverse(t *testing.T) {
+	tests := []struct {
+		sreeki   string
+		upstream string
+		changed  bool
+	}{
+		{"/sreeki/Main_Page", "/wiki/Main_Page", true},
+		{"/sreeki/Caf%C3%A9", "/wiki/Caf%C3%A9", true},
+		{"/sreeki/AC%2FDC", "/wiki/AC%2FDC", true},
+		{"/sreeki/", "/wiki/", true},
+		{"/w/index.php", "/w/index.php", false},
+		{"/", "/", false},
+	}
+
+	for _, m := range Default().Mappings {
+		for _, tt := range tests {
+			u, err := url.Parse(tt.sreeki)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed := m.UpstreamPath(u); changed != tt.changed || u.EscapedPath() != tt.upstream {
+				t.Errorf("%s: UpstreamPath(%q) = %q, %t; want %q, %t", m.Name, tt.sreeki, u.EscapedPath(), changed, tt.upstream, tt.changed)
+			}
+			if changed := m.SreekiPath(u); changed != tt.changed || u.EscapedPath() != tt.sreeki {
+				t.Errorf("%s: SreekiPath(%q) = %q, %t; want %q, %t", m.Name, tt.upstream, u.EscapedPath(), changed, tt.sreeki, tt.changed)
+			}
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package sreeify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	pb "github.com/devhou-se/sreetcode/internal/gen"
+)
+
+// ErrCircuitOpen is returned by a Breaker while it is not letting requests through to its backend.
+var ErrCircuitOpen = errors.New("sreeify circuit open")
+
+// Breaker is a Sreeifier that stops calling its backend after threshold consecutive failures. Once
+// cooldown has passed it lets a single probe request through, closing again if the probe succeeds
+// and waiting another cooldown if it fails.
+type Breaker struct {
+	next      Sreeifier
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewBreaker wraps next in a circuit breaker.
+func NewBreaker(next Sreeifier, threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		next:      next,
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+func (b *Breaker) Sreeify(ctx context.Context, input []byte) ([]byte, error) {
+	probe, err := b.allow()
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := b.next.Sreeify(ctx, input)
+	b.record(probe, err)
+	return out, err
+}
+
+// SreeifyStream sreeifies the document read from r like Sreeify, writing the result to w as it is
+// produced if the backend is a StreamSreeifier.
+func (b *Breaker) SreeifyStream(ctx context.Context, w io.Writer, r io.Reader) error {
+	probe, err := b.allow()
+	if err != nil {
+		return err
+	}
+
+	if ss, ok := b.next.(StreamSreeifier); ok {
+		err = ss.SreeifyStream(ctx, w, r)
+	} else {
+		err = sreeifyWhole(ctx, b.next, w, r)
+	}
+	b.record(probe, err)
+	return err
+}
+
+// sreeifyWhole sreeifies the whole document read from r with s, then writes the result to w.
+func sreeifyWhole(ctx context.Context, s Sreeifier, w io.Writer, r io.Reader) error {
+	input, err := io.ReadAll(r)
+	if err != nil {
+		return &StreamError{Err: err}
+	}
+	out, err := s.Sreeify(ctx, input)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(out); err != nil {
+		return &StreamError{Err: err}
+	}
+	return nil
+}
+
+// allow reports whether a request may go through, and whether it is the probe of an open circuit.
+func (b *Breaker) allow() (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return false, nil
+	}
+	if b.probing || time.Since(b.openedAt) < b.cooldown {
+		return false, ErrCircuitOpen
+	}
+
+	b.probing = true
+	return true, nil
+}
+
+// record updates the breaker with the outcome of a request.
+func (b *Breaker) record(probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if probe {
+		b.probing = false
+	}
+
+	// A caller going away says nothing about the health of the backend, so it neither closes the
+	// circuit nor counts towards opening it. A cancelled probe leaves the next request to probe.
+	var se *StreamError
+	if errors.Is(err, context.Canceled) || errors.As(err, &se) {
+		return
+	}
+
+	if !countsAsFailure(err) {
+		if b.failures >= b.threshold {
+			slog.Info("Sreeify circuit closed")
+		}
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if probe || b.failures == b.threshold {
+		b.openedAt = time.Now()
+		slog.Warn(fmt.Sprintf("Sreeify circuit open for %s after %d failures: %s", b.cooldown, b.failures, err))
+	}
+}
+
+// countsAsFailure reports whether err is a failure of the backend. The sreeifier rejecting one
+// document isn't, but an internal error is, as a server that crashes on every document is as
+// unhealthy as one that can't be reached.
+func countsAsFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	var se *ServerError
+	return !errors.As(err, &se) || se.Code == pb.Error_INTERNAL
+}
@@ -0,0 +1,83 @@
+package sreeify
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/devhou-se/sreetcode/internal/gen"
+)
+
+// sreeifierFunc is a Sreeifier that calls itself.
+type sreeifierFunc func(ctx context.Context, input []byte) ([]byte, error)
+
+func (f sreeifierFunc) Sreeify(ctx context.Context, input []byte) ([]byte, error) {
+	return f(ctx, input)
+}
+
+func TestBreaker(t *testing.T) {
+	var backendErr error
+	b := NewBreaker(sreeifierFunc(func(context.Context, []byte) ([]byte, error) {
+		return nil, backendErr
+	}), 2, time.Hour)
+	call := func(err error) error {
+		backendErr = err
+		_, err = b.Sreeify(context.Background(), []byte("x"))
+		return err
+	}
+
+	down := errors.New("connection refused")
+	call(down)
+
+	// A cancelled request mustn't reset the count of consecutive failures.
+	call(context.Canceled)
+	call(down)
+	if err := call(nil); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("after 2 failures, Sreeify() error = %v, want ErrCircuitOpen", err)
+	}
+
+	// Once the cooldown passes, a cancelled probe leaves the circuit open for the next probe.
+	b.openedAt = time.Now().Add(-2 * time.Hour)
+	call(context.Canceled)
+	if b.failures < b.threshold || b.probing {
+		t.Fatalf("after a cancelled probe, failures = %d and probing = %t, want the circuit open and no probe", b.failures, b.probing)
+	}
+	if err := call(nil); err != nil {
+		t.Fatalf("successful probe: Sreeify() error = %v", err)
+	}
+	if b.failures != 0 {
+		t.Errorf("after a successful probe, failures = %d, want 0", b.failures)
+	}
+}
+
+func TestBreakerCountsServerErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		err   error
+		opens bool
+	}{
+		{"rejected document", &ServerError{Code: pb.Error_INVALID_DOCUMENT}, false},
+		{"rejected document over v2", serverError("id", status.Error(codes.InvalidArgument, "bad")), false},
+		{"internal error", &ServerError{Code: pb.Error_INTERNAL}, true},
+		{"panic over v2", serverError("id", status.Error(codes.Unknown, "panic")), true},
+		{"internal error over v2", serverError("id", status.Error(codes.Internal, "crashed")), true},
+		{"unavailable", status.Error(codes.Unavailable, "down"), true},
+	}
+	for _, tt := range tests {
+		b := NewBreaker(sreeifierFunc(func(context.Context, []byte) ([]byte, error) {
+			return nil, tt.err
+		}), 2, time.Hour)
+		for i := 0; i < 2; i++ {
+			b.Sreeify(context.Background(), []byte("x"))
+		}
+
+		_, err := b.Sreeify(context.Background(), []byte("x"))
+		if opened := errors.Is(err, ErrCircuitOpen); opened != tt.opens {
+			t.Errorf("%s: circuit open = %t, want %t", tt.name, opened, tt.opens)
+		}
+	}
+}
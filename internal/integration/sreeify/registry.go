@@ -0,0 +1,75 @@
+package sreeify
+
+import "sync"
+
+// result is the outcome of a request to the Sreeification server.
+type result struct {
+	data []byte
+	err  error
+}
+
+// registry tracks the requests waiting on a response from the Sreeification server. It is safe for
+// concurrent use by request goroutines and the receiver.
+type registry struct {
+	mu sync.Mutex
+	m  map[string]chan result
+}
+
+func newRegistry() *registry {
+	return &registry{
+		m: make(map[string]chan result),
+	}
+}
+
+// register creates the channel the response to id will be delivered on. It must be called before
+// any part of the request is sent, so that a fast response always has somewhere to go.
+func (r *registry) register(id string) <-chan result {
+	// Buffered so that delivery never blocks, even if the caller has stopped waiting.
+	c := make(chan result, 1)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.m[id] = c
+
+	return c
+}
+
+// deliver hands res to the request waiting on id and removes it from the registry. It reports
+// whether a request was waiting.
+func (r *registry) deliver(id string, res result) bool {
+	r.mu.Lock()
+	c, ok := r.m[id]
+	delete(r.m, id)
+	r.mu.Unlock()
+
+	if ok {
+		c <- res
+	}
+	return ok
+}
+
+// waiting reports whether a request is waiting on id.
+func (r *registry) waiting(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.m[id]
+	return ok
+}
+
+// failAll fails every waiting request with err and empties the registry.
+func (r *registry) failAll(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, c := range r.m {
+		c <- result{err: err}
+		delete(r.m, id)
+	}
+}
+
+// remove forgets the request id, whether or not it has been answered.
+func (r *registry) remove(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.m, id)
+}
@@ -0,0 +1,74 @@
+package sreeify
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/devhou-se/sreetcode/internal/config"
+	"github.com/devhou-se/sreetcode/internal/util"
+)
+
+// Sreeifier backends that can be selected in the config.
+const (
+	BackendGRPC  = "grpc"
+	BackendLocal = "local"
+	BackendNoop  = "noop"
+)
+
+// Sreeifier sreeifies HTML documents.
+type Sreeifier interface {
+	Sreeify(ctx context.Context, input []byte) ([]byte, error)
+}
+
+// StreamSreeifier is a Sreeifier that can sreeify a document as it is read, writing the start of
+// the output before the end of the input has arrived.
+type StreamSreeifier interface {
+	Sreeifier
+	SreeifyStream(ctx context.Context, w io.Writer, r io.Reader) error
+}
+
+// Local is a Sreeifier that sreeifies documents in-process, without a Sreeification server.
+type Local struct{}
+
+func (Local) Sreeify(_ context.Context, input []byte) ([]byte, error) {
+	return util.SreefyHTML(input)
+}
+
+func (Local) SreeifyStream(_ context.Context, w io.Writer, r io.Reader) error {
+	return util.SreefyHTMLStream(w, r)
+}
+
+// Noop is a Sreeifier that returns documents unchanged.
+type Noop struct{}
+
+func (Noop) Sreeify(_ context.Context, input []byte) ([]byte, error) {
+	return input, nil
+}
+
+func (Noop) SreeifyStream(_ context.Context, w io.Writer, r io.Reader) error {
+	_, err := io.Copy(w, r)
+	return err
+}
+
+// New creates the Sreeifier for the backend chosen in the config. The gRPC backend is wrapped in a
+// circuit breaker unless it is disabled.
+func New(cfg config.Config) (Sreeifier, error) {
+	switch cfg.SreeifierBackend {
+	case BackendGRPC:
+		c, err := NewClient(cfg)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.BreakerThreshold <= 0 {
+			return c, nil
+		}
+		return NewBreaker(c, cfg.BreakerThreshold, cfg.BreakerCooldown), nil
+	case BackendLocal:
+		return Local{}, nil
+	case BackendNoop:
+		return Noop{}, nil
+	default:
+		return nil, fmt.Errorf("unknown sreeifier backend: %s", cfg.SreeifierBackend)
+	}
+}
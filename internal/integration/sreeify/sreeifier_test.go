@@ -0,0 +1,55 @@
+package sreeify
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/devhou-se/sreetcode/internal/config"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		backend   string
+		threshold int
+		want      string
+	}{
+		{BackendGRPC, 5, "*sreeify.Breaker"},
+		{BackendGRPC, 0, "*sreeify.Client"},
+		{BackendLocal, 5, "sreeify.Local"},
+		{BackendNoop, 5, "sreeify.Noop"},
+		{"", 5, ""},
+		{"GRPC", 5, ""},
+		{"http", 5, ""},
+	}
+	for _, tt := range tests {
+		s, err := New(config.Config{
+			SreeifierBackend: tt.backend,
+			SreeifierServer:  "localhost:0",
+			Insecure:         true,
+			SreeifyStreams:   1,
+			BreakerThreshold: tt.threshold,
+			BreakerCooldown:  time.Second,
+		})
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("New(%q) = %T, want an error", tt.backend, s)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("New(%q) error = %v", tt.backend, err)
+			continue
+		}
+		if got := fmt.Sprintf("%T", s); got != tt.want {
+			t.Errorf("New(%q) with threshold %d = %s, want %s", tt.backend, tt.threshold, got, tt.want)
+		}
+
+		switch s := s.(type) {
+		case *Client:
+			s.Close()
+		case *Breaker:
+			s.next.(*Client).Close()
+		}
+	}
+}
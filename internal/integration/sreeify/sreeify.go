@@ -1,12 +1,17 @@
 package sreeify
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"math"
+	"math/rand"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -20,15 +25,130 @@ import (
 )
 
 const (
-	chunkSize     = 1024 * 1024 // 1MB
-	pingFrequency = 15 * time.Second
+	chunkSize = 1024 * 1024 // 1MB
+	// maxResponseParts bounds the responses accepted from the server, so that a malformed total
+	// can't make the client allocate more than that for one.
+	maxResponseParts = 64
 )
 
+// How often streams ping the server, and how long they go without hearing from it before they
+// are torn down. Streams take these when they're created, so tests can shorten them.
+var (
+	pingFrequency  = 15 * time.Second
+	unhealthyAfter = 3 * pingFrequency
+)
+
+// ErrStreamClosed is returned for requests that were in flight when the stream to the
+// Sreeification server failed.
+var ErrStreamClosed = errors.New("sreeify stream closed")
+
+// connectBackoff is used both for the underlying gRPC connection and for re-establishing the stream.
+var connectBackoff = backoff.Config{
+	BaseDelay:  100 * time.Millisecond,
+	MaxDelay:   5 * time.Second,
+	Multiplier: 1.6,
+	Jitter:     0.2,
+}
+
+// State is the state of a client's stream to the Sreeification server.
+type State int32
+
+const (
+	// StateConnecting means the first stream hasn't been established yet.
+	StateConnecting State = iota
+	// StateReady means requests can be sent.
+	StateReady
+	// StateReconnecting means the stream failed and is being re-established.
+	StateReconnecting
+	// StateClosed means the client has been closed.
+	StateClosed
+)
+
+func (s State) String() string {
+	switch s {
+	case StateConnecting:
+		return "connecting"
+	case StateReady:
+		return "ready"
+	case StateReconnecting:
+		return "reconnecting"
+	case StateClosed:
+		return "closed"
+	default:
+		return fmt.Sprintf("State(%d)", int32(s))
+	}
+}
+
+// Client sreeifies documents through a pool of streams to a Sreeification server, spreading
+// requests across whichever streams are up. Servers that speak protocol v2 are sent documents of
+// up to documentLimit in calls of their own, which gRPC multiplexes over the connection, so with
+// them the pool only carries larger documents.
 type Client struct {
-	client pb.SreeificationServiceClient
-	conn   pb.SreeificationService_SreeifyClient
-	//tc     trafficcontroller.Controller[string, *pb.Payload]
-	m map[string]chan []byte
+	streams []*stream
+
+	v2 pb.SreeificationServiceV2Client
+	// protocol is the protocol version negotiated with the server.
+	protocol atomic.Int32
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// timeout bounds how long a single Sreeify call waits for its response.
+	timeout time.Duration
+}
+
+// TimeoutError is returned by Sreeify when no response arrives before the request's deadline.
+type TimeoutError struct {
+	ID string
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("sreeify request %s timed out", e.ID)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// StreamError is returned by SreeifyStream when the document can't be read or the result can't be
+// written. It says nothing about the health of the Sreeification server.
+type StreamError struct {
+	Err error
+}
+
+func (e *StreamError) Error() string {
+	return fmt.Sprintf("streaming sreeified document: %s", e.Err)
+}
+
+func (e *StreamError) Unwrap() error {
+	return e.Err
+}
+
+// errWriter is a writer that remembers the first error writing to w.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (w *errWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.w.Write(p)
+	w.err = err
+	return n, err
+}
+
+// ServerError is returned when the Sreeification server reports that it failed to sreeify a
+// document.
+type ServerError struct {
+	ID          string
+	Code        pb.Error_Code
+	Description string
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("sreeifier failed request %s: %s: %s", e.ID, e.Code, e.Description)
 }
 
 func loadTLS() grpc.DialOption {
@@ -43,17 +163,8 @@ func loadTLS() grpc.DialOption {
 }
 
 func NewClient(cfg config.Config) (*Client, error) {
-	c := &Client{}
-
-	bo := backoff.Config{
-		BaseDelay:  100 * time.Millisecond,
-		MaxDelay:   5 * time.Second,
-		Multiplier: 1.6,
-		Jitter:     0.2,
-	}
-
 	opts := []grpc.DialOption{
-		grpc.WithConnectParams(grpc.ConnectParams{Backoff: bo}),
+		grpc.WithConnectParams(grpc.ConnectParams{Backoff: connectBackoff}),
 	}
 
 	if cfg.Insecure {
@@ -67,137 +178,184 @@ func NewClient(cfg config.Config) (*Client, error) {
 		return nil, err
 	}
 
-	c.client = pb.NewSreeificationServiceClient(conn)
-
-	time.Sleep(5 * time.Second)
-	err = c.createConnection()
+	c, err := NewClientConn(conn, cfg.SreeifyStreams)
 	if err != nil {
 		return nil, err
 	}
-
-	c.m = make(map[string]chan []byte)
+	c.timeout = cfg.SreeifyTimeout
 
 	return c, nil
 }
 
-func (c *Client) Sreeify(input []byte) ([]byte, error) {
-	rawId, err := uuid.NewUUID()
-	if err != nil {
-		return nil, err
+// NewClientConn creates a new client with a pool of size streams on an existing connection to a
+// Sreeification server. It doesn't wait for the server: the client is StateConnecting until a
+// stream is up, and requests made before then wait for one until their deadline, so a server that
+// is down at startup is handled like one that goes down later.
+func NewClientConn(cc grpc.ClientConnInterface, size int) (*Client, error) {
+	if size < 1 {
+		size = 1
 	}
-	id := rawId.String()
 
-	// Chunk and send input
-	go func() {
-		chunks := chunkData(input)
-		for i, chunk := range chunks {
-			payload := &pb.Payload{
-				Id:         id,
-				Part:       int32(i),
-				TotalParts: int32(len(chunks)),
-				Data:       chunk,
-			}
-			err = c.conn.Send(&pb.Sreequest{
-				Data: &pb.Sreequest_Payload{
-					Payload: payload,
-				},
-			})
-			if err != nil {
-				slog.Error(fmt.Sprintf("Error sending chunk %d: %s", i, err))
-			}
-		}
-		if err != nil {
-			slog.Error(fmt.Sprintf("Error closing send: %s", err))
-		}
-	}()
+	client := pb.NewSreeificationServiceClient(cc)
+	c := &Client{
+		v2: pb.NewSreeificationServiceV2Client(cc),
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
 
-	// Blocking call to receive response
-	resp, err := c.receive(id)
-	if err != nil {
-		return nil, err
+	for i := 0; i < size; i++ {
+		s := newStream(client)
+		c.streams = append(c.streams, s)
+		go s.run(c.ctx)
 	}
 
-	return resp, nil
+	return c, nil
 }
 
-func (c *Client) createConnection() error {
-	ctx := context.Background()
-	conn, err := c.client.Sreeify(ctx)
-	if err != nil {
-		slog.Error(fmt.Sprintf("Error creating connection: %s", err))
-		return err
+// State returns the state of the client: ready if any of its streams are up.
+func (c *Client) State() State {
+	if c.ctx.Err() != nil {
+		return StateClosed
 	}
-	c.conn = conn
 
-	go runPing(conn)
-	go c.runReceiver(conn)
-	return nil
+	state := StateConnecting
+	for _, s := range c.streams {
+		switch s.State() {
+		case StateReady:
+			return StateReady
+		case StateReconnecting:
+			state = StateReconnecting
+		}
+	}
+	return state
 }
 
-func runPing(conn pb.SreeificationService_SreeifyClient) {
-	ticker := time.NewTicker(pingFrequency)
+// WaitReady blocks until any of the client's streams is up or ctx is done.
+func (c *Client) WaitReady(ctx context.Context) error {
+	switch c.State() {
+	case StateReady:
+		return nil
+	case StateClosed:
+		return ErrStreamClosed
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errc := make(chan error, len(c.streams))
+	for _, s := range c.streams {
+		go func(s *stream) {
+			errc <- s.WaitReady(ctx)
+		}(s)
+	}
 
-	for {
+	var err error
+	for range c.streams {
 		select {
-		case <-ticker.C:
-			err := conn.Send(&pb.Sreequest{
-				Data: &pb.Sreequest_Ping{
-					Ping: &pb.Ping{
-						Time: time.Now().UnixMicro(),
-					},
-				},
-			})
-			if err != nil {
-				slog.Error(fmt.Sprintf("Error sending ping: %s", err))
+		case err = <-errc:
+			if err == nil {
+				return nil
 			}
+		case <-c.ctx.Done():
+			return ErrStreamClosed
 		}
 	}
+	return err
 }
 
-func (c *Client) runReceiver(conn pb.SreeificationService_SreeifyClient) {
-	cc := make(chan *pb.Payload)
-	go c.collect(cc)
+// Close stops the client. Requests in flight fail with ErrStreamClosed.
+func (c *Client) Close() error {
+	c.cancel()
+	return nil
+}
 
-	for {
-		resp, err := conn.Recv()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			slog.Error(fmt.Sprintf("error receiving message: %+v", err))
-			return
-		}
+// Sreeify sends input to the Sreeification server and waits for the sreeified result. It gives up
+// when ctx is cancelled or the client's timeout passes, returning a *TimeoutError for the latter.
+// An empty document is returned as it is, without a request.
+func (c *Client) Sreeify(ctx context.Context, input []byte) ([]byte, error) {
+	if len(input) == 0 {
+		return input, nil
+	}
 
-		data := resp.GetData()
-		switch x := data.(type) {
-		case *pb.Sreesponse_Ping:
-			handlePing(x)
-		case *pb.Sreesponse_Payload:
-			cc <- x.Payload
-		}
+	var out bytes.Buffer
+	if err := c.sreeify(ctx, &out, input); err != nil {
+		return nil, err
 	}
+	return out.Bytes(), nil
 }
 
-func (c *Client) collect(cc <-chan *pb.Payload) {
-	data := make(map[string][][]byte)
+// SreeifyStream sreeifies the document read from r, writing the result to w as the server produces
+// it. Documents are sent to the server whole, so all of r is read first; it is the result that
+// streams. Servers that only speak protocol v1 send the result once it is complete. Errors reading
+// from r or writing to w are returned as a *StreamError.
+func (c *Client) SreeifyStream(ctx context.Context, w io.Writer, r io.Reader) error {
+	input, err := io.ReadAll(r)
+	if err != nil {
+		return &StreamError{Err: err}
+	}
+	if len(input) == 0 {
+		return nil
+	}
+	return c.sreeify(ctx, w, input)
+}
 
-	f := func(b []byte) bool {
-		return b != nil
+// sreeify sends input to the Sreeification server, writing the result to w.
+func (c *Client) sreeify(ctx context.Context, w io.Writer, input []byte) error {
+	var cancel context.CancelFunc
+	if c.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
 	}
+	// Cancelling also tells the stream's send loop to skip the request if it hasn't been sent yet.
+	defer cancel()
 
-	for payload := range cc {
-		id := payload.GetId()
-		if _, ok := data[id]; !ok {
-			data[id] = make([][]byte, payload.TotalParts)
+	rawId, err := uuid.NewUUID()
+	if err != nil {
+		return err
+	}
+	id := rawId.String()
+
+	ew := &errWriter{w: w}
+	if ok, err := c.sreeifyV2(ctx, ew, input); ok {
+		if ew.err != nil {
+			return &StreamError{Err: ew.err}
 		}
-		data[id][payload.GetPart()] = payload.GetData()
+		if err != nil {
+			return contextError(ctx, id, serverError(id, err))
+		}
+		return nil
+	}
 
-		if all(data[id], f) {
-			b := flatten(data[id])
-			co := c.m[id]
-			co <- b
+	if err := c.WaitReady(ctx); err != nil {
+		return contextError(ctx, id, err)
+	}
+
+	out, err := c.pick().do(ctx, id, input)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(out); err != nil {
+		return &StreamError{Err: err}
+	}
+	return nil
+}
+
+// pick chooses the ready stream with the fewest requests in flight.
+func (c *Client) pick() *stream {
+	var best *stream
+	for _, s := range c.streams {
+		if s.State() != StateReady {
+			continue
 		}
+		if best == nil || s.inflight.Load() < best.inflight.Load() {
+			best = s
+		}
+	}
+	if best == nil {
+		// Every stream has gone down since WaitReady; the request waits for one to come back up.
+		best = c.streams[0]
 	}
+	return best
 }
 
 func flatten(bs [][]byte) []byte {
@@ -208,23 +366,41 @@ func flatten(bs [][]byte) []byte {
 	return b
 }
 
-func all(i [][]byte, f func([]byte) bool) bool {
-	for _, x := range i {
-		if !f(x) {
-			return false
-		}
+// receive waits for the response to request id to arrive on pc.
+func receive(ctx context.Context, id string, pc <-chan result) ([]byte, error) {
+	select {
+	case res := <-pc:
+		return res.data, res.err
+	case <-ctx.Done():
+		return nil, contextError(ctx, id, ctx.Err())
 	}
-	return true
 }
 
-func (c *Client) receive(id string) ([]byte, error) {
-	pc := make(chan []byte)
-	c.m[id] = pc
-	data := <-pc
-	return data, nil
+// contextError converts err into a *TimeoutError if ctx's deadline has passed.
+func contextError(ctx context.Context, id string, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &TimeoutError{ID: id}
+	}
+	return err
+}
+
+// backoffDelay returns how long to wait before reconnect attempt n, following connectBackoff.
+func backoffDelay(n int) time.Duration {
+	d := float64(connectBackoff.BaseDelay) * math.Pow(connectBackoff.Multiplier, float64(n))
+	if max := float64(connectBackoff.MaxDelay); d > max {
+		d = max
+	}
+	d *= 1 + connectBackoff.Jitter*(rand.Float64()*2-1)
+	return time.Duration(d)
 }
 
+// chunkData splits b into chunks of at most chunkSize. An empty document is sent as a single empty
+// chunk, as the server only responds to requests it has received a part of.
 func chunkData(b []byte) [][]byte {
+	if len(b) == 0 {
+		return [][]byte{{}}
+	}
+
 	var bs [][]byte
 	for i := 0; i < len(b); i += chunkSize {
 		end := i + chunkSize
@@ -0,0 +1,339 @@
+package sreeify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+
+	pb "github.com/devhou-se/sreetcode/internal/gen"
+	"github.com/devhou-se/sreetcode/internal/grpctest"
+)
+
+// echoServer is a protocol v1 Sreeification server that sends each document back unchanged, in
+// reverse part order, from a goroutine per document so that responses interleave.
+type echoServer struct {
+	pb.UnimplementedSreeificationServiceServer
+}
+
+func (echoServer) Sreeify(stream pb.SreeificationService_SreeifyServer) error {
+	var mu sync.Mutex
+	send := func(resp *pb.Sreesponse) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return stream.Send(resp)
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	parts := make(map[string][]*pb.Payload)
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		payload := req.GetPayload()
+		if payload == nil {
+			continue
+		}
+		id := payload.GetId()
+		parts[id] = append(parts[id], payload)
+		if len(parts[id]) < int(payload.GetTotalParts()) {
+			continue
+		}
+		doc := parts[id]
+		delete(parts, id)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := len(doc) - 1; i >= 0; i-- {
+				send(&pb.Sreesponse{Data: &pb.Sreesponse_Payload{Payload: doc[i]}})
+			}
+		}()
+	}
+}
+
+// newTestClient starts srv on an in-memory connection and returns a client with size streams
+// connected to it.
+func newTestClient(t *testing.T, srv pb.SreeificationServiceServer, size int) *Client {
+	t.Helper()
+
+	conn := grpctest.Serve(t, func(s *grpc.Server) {
+		pb.RegisterSreeificationServiceServer(s, srv)
+	})
+
+	c, err := NewClientConn(conn, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.timeout = 30 * time.Second
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// TestConcurrentSreeify runs many requests at once across a pool of streams, checking each gets
+// its own response. Run it with -race to check the registry and streams for data races.
+func TestConcurrentSreeify(t *testing.T) {
+	c := newTestClient(t, echoServer{}, 4)
+
+	const calls = 500
+	var wg sync.WaitGroup
+	errs := make(chan error, calls)
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			input := []byte(fmt.Sprintf("<p>document %d</p>", i))
+			if i%100 == 0 {
+				// Some documents span several chunks.
+				input = bytes.Repeat(input, 2*chunkSize/len(input)+1)
+			}
+
+			out, err := c.Sreeify(context.Background(), input)
+			switch {
+			case err != nil:
+				errs <- fmt.Errorf("call %d: %w", i, err)
+			case !bytes.Equal(out, input):
+				errs <- fmt.Errorf("call %d: got %d bytes, want %d", i, len(out), len(input))
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestSreeifyEmpty(t *testing.T) {
+	c := newTestClient(t, echoServer{}, 1)
+	c.timeout = time.Second
+
+	out, err := c.Sreeify(context.Background(), nil)
+	if err != nil || len(out) != 0 {
+		t.Errorf("Sreeify(nil) = %q, %v; want empty output and no error", out, err)
+	}
+}
+
+// malformedServer answers every request with a single payload numbered part of total.
+type malformedServer struct {
+	pb.UnimplementedSreeificationServiceServer
+	part, total int32
+}
+
+func (m malformedServer) Sreeify(stream pb.SreeificationService_SreeifyServer) error {
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return nil
+		}
+		if payload := req.GetPayload(); payload != nil {
+			stream.Send(&pb.Sreesponse{Data: &pb.Sreesponse_Payload{Payload: &pb.Payload{
+				Id:         payload.GetId(),
+				Part:       m.part,
+				TotalParts: m.total,
+				Data:       []byte("x"),
+			}}})
+		}
+	}
+}
+
+func TestSreeifyMalformedResponse(t *testing.T) {
+	tests := []struct {
+		name        string
+		part, total int32
+	}{
+		{"part past the end", 2, 2},
+		{"negative part", -1, 1},
+		{"no parts", 0, 0},
+		{"too many parts", 0, math.MaxInt32},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, malformedServer{part: tt.part, total: tt.total}, 1)
+			c.timeout = 5 * time.Second
+
+			// The receiver must survive to answer the second request too.
+			for i := 0; i < 2; i++ {
+				_, err := c.Sreeify(context.Background(), []byte("<p>document</p>"))
+				var se *ServerError
+				if !errors.As(err, &se) {
+					t.Fatalf("Sreeify() error = %v, want a *ServerError", err)
+				}
+			}
+		})
+	}
+}
+
+func TestNewClientConnDoesNotWait(t *testing.T) {
+	// Nothing is listening, so no stream can come up.
+	lis := bufconn.Listen(1024)
+	lis.Close()
+	conn := grpctest.Dial(t, lis)
+
+	c, err := NewClientConn(conn, 1)
+	if err != nil {
+		t.Fatalf("NewClientConn() error = %v", err)
+	}
+	defer c.Close()
+	if state := c.State(); state != StateConnecting {
+		t.Errorf("State() = %s, want %s", state, StateConnecting)
+	}
+
+	c.timeout = 100 * time.Millisecond
+	var te *TimeoutError
+	if _, err := c.Sreeify(context.Background(), []byte("x")); !errors.As(err, &te) {
+		t.Errorf("Sreeify() error = %v, want a *TimeoutError", err)
+	}
+}
+
+// echoV2Server is a protocol v2 Sreeification server that sends each document back unchanged,
+// counting the calls made to it.
+type echoV2Server struct {
+	pb.UnimplementedSreeificationServiceV2Server
+	calls *atomic.Int32
+}
+
+func (s echoV2Server) SreeifyDocument(_ context.Context, doc *pb.Document) (*pb.Document, error) {
+	s.calls.Add(1)
+	return doc, nil
+}
+
+func (s echoV2Server) SreeifyDocumentStream(doc *pb.Document, stream pb.SreeificationServiceV2_SreeifyDocumentStreamServer) error {
+	s.calls.Add(1)
+	for _, chunk := range chunkData(doc.GetData()) {
+		if err := stream.Send(&pb.DocumentChunk{Data: chunk}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// holdServer is a protocol v1 server that echoes documents like echoServer, but holds every
+// response until it has received hold documents, counting the streams they arrive on.
+type holdServer struct {
+	pb.UnimplementedSreeificationServiceServer
+	hold    int32
+	docs    *atomic.Int32
+	streams *atomic.Int32
+	release chan struct{}
+	once    *sync.Once
+}
+
+func (h holdServer) Sreeify(stream pb.SreeificationService_SreeifyServer) error {
+	used := false
+	parts := make(map[string][]*pb.Payload)
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return nil
+		}
+		payload := req.GetPayload()
+		if payload == nil {
+			continue
+		}
+		id := payload.GetId()
+		parts[id] = append(parts[id], payload)
+		if len(parts[id]) < int(payload.GetTotalParts()) {
+			continue
+		}
+		doc := parts[id]
+		delete(parts, id)
+
+		if !used {
+			used = true
+			h.streams.Add(1)
+		}
+		if h.docs.Add(1) == h.hold {
+			h.once.Do(func() { close(h.release) })
+		}
+		go func() {
+			<-h.release
+			for _, p := range doc {
+				stream.Send(&pb.Sreesponse{Data: &pb.Sreesponse_Payload{Payload: p}})
+			}
+		}()
+	}
+}
+
+// TestPoolUnderV2 checks that once protocol v2 is in use, documents too large to send whole over
+// it are still spread across the pool of v1 streams, and the rest are sent over v2.
+func TestPoolUnderV2(t *testing.T) {
+	var calls, docs, streams atomic.Int32
+	conn := grpctest.Serve(t, func(s *grpc.Server) {
+		pb.RegisterSreeificationServiceServer(s, holdServer{
+			hold:    2,
+			docs:    &docs,
+			streams: &streams,
+			release: make(chan struct{}),
+			once:    &sync.Once{},
+		})
+		pb.RegisterSreeificationServiceV2Server(s, echoV2Server{calls: &calls})
+	})
+	c, err := NewClientConn(conn, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if out, err := c.Sreeify(ctx, []byte("<p>small</p>")); err != nil || string(out) != "<p>small</p>" {
+		t.Fatalf("Sreeify() = %q, %v; want the document back", out, err)
+	}
+	for _, s := range c.streams {
+		if err := s.WaitReady(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	large := bytes.Repeat([]byte("<p>large</p>"), documentLimit/12+1)
+	errs := make(chan error, 2)
+	send := func() {
+		out, err := c.Sreeify(ctx, large)
+		if err == nil && !bytes.Equal(out, large) {
+			err = fmt.Errorf("got %d bytes, want %d", len(out), len(large))
+		}
+		errs <- err
+	}
+
+	// The second document goes to the stream the first isn't waiting on.
+	go send()
+	for c.streams[0].inflight.Load()+c.streams[1].inflight.Load() == 0 {
+		if ctx.Err() != nil {
+			t.Fatal("first document wasn't sent")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	go send()
+
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+	if n := streams.Load(); n != 2 {
+		t.Errorf("large documents arrived on %d streams, want 2", n)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("server got %d v2 calls, want 1 for the small document", n)
+	}
+}
@@ -0,0 +1,341 @@
+package sreeify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+
+	pb "github.com/devhou-se/sreetcode/internal/gen"
+)
+
+// errUnhealthy is the reason a stream is torn down when the server stops answering pings.
+var errUnhealthy = errors.New("no response from server")
+
+// outgoing is a request queued for a stream's send loop.
+type outgoing struct {
+	ctx   context.Context
+	id    string
+	input []byte
+}
+
+// stream is one of a client's streams to the Sreeification server. It keeps itself connected,
+// owns the only goroutine that sends on the underlying gRPC stream, and tracks the requests sent
+// on it so they can be failed if it goes down.
+type stream struct {
+	client  pb.SreeificationServiceClient
+	pending *registry
+	sendc   chan outgoing
+
+	// mu guards ready, which is closed while the stream is up and replaced when it goes down.
+	mu    sync.Mutex
+	ready chan struct{}
+	state atomic.Int32
+
+	// inflight is the number of requests waiting on this stream, used for load balancing.
+	inflight atomic.Int64
+	// lastSeen is when a message was last received, in Unix nanoseconds.
+	lastSeen atomic.Int64
+
+	pingFrequency  time.Duration
+	unhealthyAfter time.Duration
+}
+
+func newStream(client pb.SreeificationServiceClient) *stream {
+	return &stream{
+		client:  client,
+		pending: newRegistry(),
+		sendc:   make(chan outgoing),
+		ready:   make(chan struct{}),
+
+		pingFrequency:  pingFrequency,
+		unhealthyAfter: unhealthyAfter,
+	}
+}
+
+// State returns the current state of the stream.
+func (s *stream) State() State {
+	return State(s.state.Load())
+}
+
+// WaitReady blocks until the stream is up or ctx is done.
+func (s *stream) WaitReady(ctx context.Context) error {
+	s.mu.Lock()
+	ready := s.ready
+	s.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// do sends input as request id and waits for the response.
+func (s *stream) do(ctx context.Context, id string, input []byte) ([]byte, error) {
+	s.inflight.Add(1)
+	defer s.inflight.Add(-1)
+
+	// Register before sending so the response can't arrive before there's anywhere to put it.
+	pc := s.pending.register(id)
+	defer s.pending.remove(id)
+
+	select {
+	case s.sendc <- outgoing{ctx: ctx, id: id, input: input}:
+	case res := <-pc:
+		return res.data, res.err
+	case <-ctx.Done():
+		return nil, contextError(ctx, id, ctx.Err())
+	}
+
+	return receive(ctx, id, pc)
+}
+
+// run keeps the stream connected until ctx is done, re-establishing it with backoff whenever it
+// fails or stops answering pings.
+func (s *stream) run(ctx context.Context) {
+	for attempt := 0; ctx.Err() == nil; attempt++ {
+		sctx, cancel := context.WithCancelCause(ctx)
+
+		conn, err := s.client.Sreeify(sctx, grpc.WaitForReady(true))
+		if err != nil {
+			cancel(err)
+			delay := backoffDelay(attempt)
+			slog.Error(fmt.Sprintf("Error creating connection, retrying in %s: %s", delay, err))
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+			}
+			continue
+		}
+		attempt = -1
+
+		s.lastSeen.Store(time.Now().UnixNano())
+		s.setReady()
+		go s.runSender(sctx, cancel, conn)
+		err = s.runReceiver(conn)
+		cancel(err)
+
+		s.setNotReady()
+		if cause := context.Cause(sctx); cause != nil {
+			err = cause
+		}
+		slog.Error(fmt.Sprintf("Sreeify stream failed: %s", err))
+		s.pending.failAll(fmt.Errorf("%w: %s", ErrStreamClosed, err))
+	}
+
+	s.state.Store(int32(StateClosed))
+	s.pending.failAll(ErrStreamClosed)
+}
+
+func (s *stream) setReady() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.state.Store(int32(StateReady))
+	close(s.ready)
+}
+
+func (s *stream) setNotReady() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.state.Store(int32(StateReconnecting))
+	s.ready = make(chan struct{})
+}
+
+// runSender is the only goroutine that sends on conn. It sends queued requests and pings the
+// server, tearing the stream down if nothing has been heard from the server for too long.
+func (s *stream) runSender(ctx context.Context, cancel context.CancelCauseFunc, conn pb.SreeificationService_SreeifyClient) {
+	ticker := time.NewTicker(s.pingFrequency)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if time.Since(time.Unix(0, s.lastSeen.Load())) > s.unhealthyAfter {
+				cancel(errUnhealthy)
+				return
+			}
+			err := conn.Send(&pb.Sreequest{
+				Data: &pb.Sreequest_Ping{
+					Ping: &pb.Ping{
+						Time: time.Now().UnixMicro(),
+					},
+				},
+			})
+			if err != nil {
+				slog.Error(fmt.Sprintf("Error sending ping: %s", err))
+			}
+		case o := <-s.sendc:
+			// Skip requests whose caller has already given up.
+			if o.ctx.Err() != nil {
+				continue
+			}
+			if err := sendPayload(conn, o); err != nil {
+				slog.Error(fmt.Sprintf("Error sending request %s: %s", o.id, err))
+				s.pending.deliver(o.id, result{err: fmt.Errorf("%w: %s", ErrStreamClosed, err)})
+			}
+		}
+	}
+}
+
+// sendPayload sends a request in chunks.
+func sendPayload(conn pb.SreeificationService_SreeifyClient, o outgoing) error {
+	chunks := chunkData(o.input)
+	for i, chunk := range chunks {
+		payload := &pb.Payload{
+			Id:         o.id,
+			Part:       int32(i),
+			TotalParts: int32(len(chunks)),
+			Data:       chunk,
+		}
+		err := conn.Send(&pb.Sreequest{
+			Data: &pb.Sreequest_Payload{
+				Payload: payload,
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("sending chunk %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// runReceiver handles responses on conn until the stream ends, returning the reason it ended.
+func (s *stream) runReceiver(conn pb.SreeificationService_SreeifyClient) error {
+	cc := make(chan *pb.Sreesponse)
+	defer close(cc)
+	go s.collect(cc)
+
+	for {
+		resp, err := conn.Recv()
+		if err != nil {
+			return err
+		}
+		s.lastSeen.Store(time.Now().UnixNano())
+
+		data := resp.GetData()
+		switch x := data.(type) {
+		case *pb.Sreesponse_Ping:
+			handlePing(x)
+		case *pb.Sreesponse_Payload, *pb.Sreesponse_Error:
+			cc <- resp
+		}
+	}
+}
+
+// collect reassembles chunked payloads and delivers complete responses and errors to the requests
+// waiting on them. The parts of responses whose request has given up are dropped as they arrive,
+// and those already collected are swept away every pingFrequency, so a server that never finishes
+// them can't make the client hold on to them.
+func (s *stream) collect(cc <-chan *pb.Sreesponse) {
+	responses := make(map[string]*response)
+	ticker := time.NewTicker(s.pingFrequency)
+	defer ticker.Stop()
+
+	for {
+		var resp *pb.Sreesponse
+		select {
+		case r, ok := <-cc:
+			if !ok {
+				return
+			}
+			resp = r
+		case <-ticker.C:
+			for id := range responses {
+				if !s.pending.waiting(id) {
+					delete(responses, id)
+				}
+			}
+			continue
+		}
+
+		var id string
+		var res result
+
+		switch x := resp.GetData().(type) {
+		case *pb.Sreesponse_Error:
+			id = x.Error.GetId()
+			res.err = &ServerError{
+				ID:          id,
+				Code:        x.Error.GetCode(),
+				Description: x.Error.GetDescription(),
+			}
+			delete(responses, id)
+		case *pb.Sreesponse_Payload:
+			payload := x.Payload
+			id = payload.GetId()
+			if !s.pending.waiting(id) {
+				delete(responses, id)
+				slog.Warn(fmt.Sprintf("Dropping response %s with no waiting request", id))
+				continue
+			}
+
+			total, part := payload.GetTotalParts(), payload.GetPart()
+			r, ok := responses[id]
+			if total <= 0 || total > maxResponseParts || part < 0 || part >= total || ok && len(r.parts) != int(total) {
+				// A malformed payload fails the request rather than the receiver.
+				res.err = &ServerError{
+					ID:          id,
+					Code:        pb.Error_INTERNAL,
+					Description: fmt.Sprintf("invalid part %d of %d in response", part, total),
+				}
+				delete(responses, id)
+				break
+			}
+
+			if !ok {
+				r = newResponse(int(total))
+				responses[id] = r
+			}
+			r.add(int(part), payload.GetData())
+
+			if r.remaining > 0 {
+				continue
+			}
+			res.data = flatten(r.parts)
+			delete(responses, id)
+		}
+
+		// The caller may have given up already, in which case there's nobody to deliver to.
+		if !s.pending.deliver(id, res) {
+			slog.Warn(fmt.Sprintf("Dropping response %s with no waiting request", id))
+		}
+	}
+}
+
+// response collects the parts of a chunked response as they arrive. Parts are counted rather than
+// checked for data, as an empty part arrives without any.
+type response struct {
+	parts     [][]byte
+	seen      []bool
+	remaining int
+}
+
+func newResponse(total int) *response {
+	return &response{
+		parts:     make([][]byte, total),
+		seen:      make([]bool, total),
+		remaining: total,
+	}
+}
+
+// add stores a part of the response, which must be in range. Parts may arrive in any order and
+// duplicates are ignored.
+func (r *response) add(part int, data []byte) {
+	if r.seen[part] {
+		return
+	}
+	r.parts[part] = data
+	r.seen[part] = true
+	r.remaining--
+}
@@ -0,0 +1,375 @@
+package sreeify
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/devhou-se/sreetcode/internal/gen"
+)
+
+// dropServer drops each of its first drops streams once it receives a payload on it, and echoes
+// documents on the streams after that.
+type dropServer struct {
+	pb.UnimplementedSreeificationServiceServer
+	drops   int32
+	streams *atomic.Int32
+}
+
+func (d dropServer) Sreeify(stream pb.SreeificationService_SreeifyServer) error {
+	if d.streams.Add(1) > d.drops {
+		return echoServer{}.Sreeify(stream)
+	}
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		if req.GetPayload() != nil {
+			return status.Error(codes.Unavailable, "dropped")
+		}
+	}
+}
+
+// silentServer accepts streams and reads from them, but never sends anything back.
+type silentServer struct {
+	pb.UnimplementedSreeificationServiceServer
+	streams *atomic.Int32
+}
+
+func (s silentServer) Sreeify(stream pb.SreeificationService_SreeifyServer) error {
+	s.streams.Add(1)
+	for {
+		if _, err := stream.Recv(); err != nil {
+			return err
+		}
+	}
+}
+
+func TestStreamReconnects(t *testing.T) {
+	var streams atomic.Int32
+	c := newTestClient(t, dropServer{drops: 1, streams: &streams}, 1)
+
+	// The request in flight when the server drops the stream fails.
+	_, err := c.Sreeify(context.Background(), []byte("<p>first</p>"))
+	if !errors.Is(err, ErrStreamClosed) {
+		t.Fatalf("Sreeify() error = %v, want ErrStreamClosed", err)
+	}
+
+	// The next one waits for the stream to come back up.
+	out, err := c.Sreeify(context.Background(), []byte("<p>second</p>"))
+	if err != nil || string(out) != "<p>second</p>" {
+		t.Fatalf("Sreeify() = %q, %v; want the document back", out, err)
+	}
+	if n := streams.Load(); n != 2 {
+		t.Errorf("server saw %d streams, want 2", n)
+	}
+	if state := c.State(); state != StateReady {
+		t.Errorf("State() = %s, want %s", state, StateReady)
+	}
+}
+
+func TestStreamTornDownWithoutPings(t *testing.T) {
+	defer func(ping, unhealthy time.Duration) {
+		pingFrequency, unhealthyAfter = ping, unhealthy
+	}(pingFrequency, unhealthyAfter)
+	pingFrequency, unhealthyAfter = 20*time.Millisecond, 100*time.Millisecond
+
+	var streams atomic.Int32
+	c := newTestClient(t, silentServer{streams: &streams}, 1)
+
+	_, err := c.Sreeify(context.Background(), []byte("<p>document</p>"))
+	if !errors.Is(err, ErrStreamClosed) || !strings.Contains(err.Error(), errUnhealthy.Error()) {
+		t.Fatalf("Sreeify() error = %v, want ErrStreamClosed for an unhealthy stream", err)
+	}
+
+	// The stream is re-established after being torn down.
+	deadline := time.Now().Add(5 * time.Second)
+	for streams.Load() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("stream wasn't re-established")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCloseFailsRequestsInFlight(t *testing.T) {
+	var streams atomic.Int32
+	c := newTestClient(t, silentServer{streams: &streams}, 2)
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := c.Sreeify(context.Background(), []byte("<p>document</p>"))
+		errc <- err
+	}()
+
+	// Wait for the request to be sent before closing.
+	deadline := time.Now().Add(5 * time.Second)
+	for c.streams[0].inflight.Load()+c.streams[1].inflight.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("request wasn't sent")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Close()
+
+	if err := <-errc; !errors.Is(err, ErrStreamClosed) {
+		t.Errorf("Sreeify() error = %v, want ErrStreamClosed", err)
+	}
+	if err := c.WaitReady(context.Background()); !errors.Is(err, ErrStreamClosed) {
+		t.Errorf("WaitReady() after Close = %v, want ErrStreamClosed", err)
+	}
+}
+
+// failServer answers documents containing "fail" with an error, and echoes the rest, once it has
+// received two documents so that the error and the response are in flight together.
+type failServer struct {
+	pb.UnimplementedSreeificationServiceServer
+}
+
+func (failServer) Sreeify(stream pb.SreeificationService_SreeifyServer) error {
+	var docs []*pb.Payload
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		if p := req.GetPayload(); p != nil {
+			docs = append(docs, p)
+		}
+		if len(docs) < 2 {
+			continue
+		}
+
+		for _, p := range docs {
+			resp := &pb.Sreesponse{Data: &pb.Sreesponse_Payload{Payload: p}}
+			if strings.Contains(string(p.GetData()), "fail") {
+				resp = &pb.Sreesponse{Data: &pb.Sreesponse_Error{Error: &pb.Error{
+					Id:          p.GetId(),
+					Code:        pb.Error_INVALID_DOCUMENT,
+					Description: "can't parse document",
+				}}}
+			}
+			if err := stream.Send(resp); err != nil {
+				return err
+			}
+		}
+		docs = nil
+	}
+}
+
+func TestServerErrorFailsOnlyItsRequest(t *testing.T) {
+	c := newTestClient(t, failServer{}, 1)
+
+	type reply struct {
+		out []byte
+		err error
+	}
+	failed, succeeded := make(chan reply, 1), make(chan reply, 1)
+	go func() {
+		out, err := c.Sreeify(context.Background(), []byte("<p>fail</p>"))
+		failed <- reply{out, err}
+	}()
+	go func() {
+		out, err := c.Sreeify(context.Background(), []byte("<p>document</p>"))
+		succeeded <- reply{out, err}
+	}()
+
+	r := <-failed
+	var se *ServerError
+	if !errors.As(r.err, &se) {
+		t.Fatalf("Sreeify() error = %v, want a *ServerError", r.err)
+	}
+	if se.Code != pb.Error_INVALID_DOCUMENT || se.Description != "can't parse document" || se.ID == "" {
+		t.Errorf("ServerError = %+v, want INVALID_DOCUMENT with the server's description", se)
+	}
+
+	r = <-succeeded
+	if r.err != nil || string(r.out) != "<p>document</p>" {
+		t.Errorf("Sreeify() = %q, %v; want the document back", r.out, r.err)
+	}
+	if state := c.State(); state != StateReady {
+		t.Errorf("State() = %s after a server error, want %s", state, StateReady)
+	}
+}
+
+// pendingCount returns the number of requests waiting on s.
+func pendingCount(s *stream) int {
+	s.pending.mu.Lock()
+	defer s.pending.mu.Unlock()
+	return len(s.pending.m)
+}
+
+func TestStreamDoRemovesPendingRequests(t *testing.T) {
+	tests := []struct {
+		name string
+		// send takes the request off the stream's send loop, or leaves it blocked if it is nil.
+		send func(s *stream, o outgoing)
+		// ctx returns the request's context.
+		ctx     func() (context.Context, context.CancelFunc)
+		wantErr func(error) bool
+	}{
+		{
+			name: "cancelled while waiting to send",
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(20*time.Millisecond, cancel)
+				return ctx, cancel
+			},
+			wantErr: func(err error) bool { return errors.Is(err, context.Canceled) },
+		},
+		{
+			name: "deadline while waiting to send",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 20*time.Millisecond)
+			},
+			wantErr: func(err error) bool { var te *TimeoutError; return errors.As(err, &te) },
+		},
+		{
+			name: "cancelled after sending",
+			send: func(*stream, outgoing) {},
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				time.AfterFunc(20*time.Millisecond, cancel)
+				return ctx, cancel
+			},
+			wantErr: func(err error) bool { return errors.Is(err, context.Canceled) },
+		},
+		{
+			name: "deadline after sending",
+			send: func(*stream, outgoing) {},
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 20*time.Millisecond)
+			},
+			wantErr: func(err error) bool { var te *TimeoutError; return errors.As(err, &te) },
+		},
+		{
+			name: "response",
+			send: func(s *stream, o outgoing) { s.pending.deliver(o.id, result{data: o.input}) },
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 5*time.Second)
+			},
+			wantErr: func(err error) bool { return err == nil },
+		},
+	}
+
+	for _, tt := range tests {
+		s := newStream(nil)
+		if send := tt.send; send != nil {
+			go func() {
+				send(s, <-s.sendc)
+			}()
+		}
+
+		ctx, cancel := tt.ctx()
+		_, err := s.do(ctx, "id", []byte("<p>document</p>"))
+		cancel()
+		if !tt.wantErr(err) {
+			t.Errorf("%s: do() error = %v", tt.name, err)
+		}
+		if n := pendingCount(s); n != 0 {
+			t.Errorf("%s: %d requests left pending, want 0", tt.name, n)
+		}
+	}
+}
+
+func TestStreamDoResponseBeforeSend(t *testing.T) {
+	s := newStream(nil)
+
+	// The request is answered, say by the stream failing, while it is still waiting to be sent.
+	go func() {
+		for pendingCount(s) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		s.pending.failAll(ErrStreamClosed)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := s.do(ctx, "id", []byte("<p>document</p>")); !errors.Is(err, ErrStreamClosed) {
+		t.Errorf("do() error = %v, want ErrStreamClosed", err)
+	}
+	if n := pendingCount(s); n != 0 {
+		t.Errorf("%d requests left pending, want 0", n)
+	}
+}
+
+func TestSreeifyCancelled(t *testing.T) {
+	var streams atomic.Int32
+	c := newTestClient(t, silentServer{streams: &streams}, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	_, err := c.Sreeify(ctx, []byte("<p>document</p>"))
+
+	var te *TimeoutError
+	if !errors.Is(err, context.Canceled) || errors.As(err, &te) {
+		t.Errorf("Sreeify() error = %v, want context.Canceled and not a *TimeoutError", err)
+	}
+	if n := pendingCount(c.streams[0]); n != 0 {
+		t.Errorf("%d requests left pending, want 0", n)
+	}
+}
+
+func TestCollectDropsAbandonedResponses(t *testing.T) {
+	part := func(id string, part int32, data string) *pb.Sreesponse {
+		return &pb.Sreesponse{Data: &pb.Sreesponse_Payload{Payload: &pb.Payload{
+			Id: id, Part: part, TotalParts: 2, Data: []byte(data),
+		}}}
+	}
+
+	tests := []struct {
+		name string
+		// abandon gives up on request id while the stale part is being received.
+		abandon func(s *stream, cc chan<- *pb.Sreesponse, id string)
+		sweep   time.Duration
+	}{
+		{
+			name: "abandoned before its first part",
+			abandon: func(s *stream, cc chan<- *pb.Sreesponse, id string) {
+				cc <- part(id, 0, "stale")
+			},
+			sweep: time.Hour,
+		},
+		{
+			name: "abandoned after its first part",
+			abandon: func(s *stream, cc chan<- *pb.Sreesponse, id string) {
+				s.pending.register(id)
+				cc <- part(id, 0, "stale")
+				s.pending.remove(id)
+				time.Sleep(50 * time.Millisecond)
+			},
+			sweep: 10 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		s := newStream(nil)
+		s.pingFrequency = tt.sweep
+		cc := make(chan *pb.Sreesponse)
+		go s.collect(cc)
+
+		tt.abandon(s, cc, "id")
+
+		// A request reusing the id gets only the parts sent for it.
+		pc := s.pending.register("id")
+		cc <- part("id", 1, "-done")
+		cc <- part("id", 0, "fresh")
+		close(cc)
+
+		select {
+		case res := <-pc:
+			if res.err != nil || string(res.data) != "fresh-done" {
+				t.Errorf("%s: got %q, %v; want %q", tt.name, res.data, res.err, "fresh-done")
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("%s: no response", tt.name)
+		}
+	}
+}
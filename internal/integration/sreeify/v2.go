@@ -0,0 +1,139 @@
+package sreeify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/devhou-se/sreetcode/internal/gen"
+)
+
+const (
+	// Documents up to unaryLimit are sreeified with a single unary call.
+	unaryLimit = 256 * 1024 // 256KB
+	// Documents up to documentLimit are sent whole over protocol v2, staying under gRPC's default
+	// 4MB message size. Larger documents are chunked over the v1 stream.
+	documentLimit = 3 * 1024 * 1024 // 3MB
+)
+
+// Protocol versions the client can negotiate with the server.
+const (
+	protocolUnknown int32 = iota
+	protocolV1
+	protocolV2
+)
+
+// Metadata describes a document being sreeified. It is sent to servers that speak protocol v2.
+type Metadata struct {
+	ContentType string
+	Charset     string
+	SourceURL   string
+	Language    string
+}
+
+type metadataKey struct{}
+
+// WithMetadata returns a copy of ctx carrying metadata for the document being sreeified.
+func WithMetadata(ctx context.Context, md Metadata) context.Context {
+	return context.WithValue(ctx, metadataKey{}, md)
+}
+
+func metadataFromContext(ctx context.Context) *pb.Metadata {
+	md, _ := ctx.Value(metadataKey{}).(Metadata)
+	return &pb.Metadata{
+		ContentType: md.ContentType,
+		Charset:     md.Charset,
+		SourceUrl:   md.SourceURL,
+		Language:    md.Language,
+	}
+}
+
+// sreeifyV2 sreeifies input over protocol v2 if the server supports it, writing the result to w. It
+// reports false if the document should go over the v1 stream instead.
+//
+// The first call negotiates the protocol: a server that doesn't implement v2 is remembered, and
+// every later request goes straight to v1.
+func (c *Client) sreeifyV2(ctx context.Context, w io.Writer, input []byte) (bool, error) {
+	if len(input) > documentLimit || c.protocol.Load() == protocolV1 {
+		return false, nil
+	}
+
+	err := c.sreeifyDocument(ctx, w, input)
+	if status.Code(err) == codes.Unimplemented {
+		if c.protocol.Swap(protocolV1) != protocolV1 {
+			slog.Info("Sreeification server doesn't support protocol v2, using v1")
+		}
+		return false, nil
+	}
+	if err == nil && c.protocol.Swap(protocolV2) != protocolV2 {
+		slog.Info("Using protocol v2 with sreeification server")
+	}
+
+	return true, err
+}
+
+// serverError converts the status of a failed v2 call into a *ServerError if the server reported
+// that it couldn't sreeify the document. Other errors are returned unchanged.
+func serverError(id string, err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	var code pb.Error_Code
+	switch st.Code() {
+	case codes.InvalidArgument:
+		code = pb.Error_INVALID_DOCUMENT
+	case codes.Internal, codes.Unknown:
+		code = pb.Error_INTERNAL
+	default:
+		return err
+	}
+
+	return &ServerError{
+		ID:          id,
+		Code:        code,
+		Description: st.Message(),
+	}
+}
+
+// sreeifyDocument sends input as a single document, using the unary call for small documents and
+// the server-streaming call for the rest, whose chunks are written to w as they arrive.
+func (c *Client) sreeifyDocument(ctx context.Context, w io.Writer, input []byte) error {
+	doc := &pb.Document{
+		Metadata: metadataFromContext(ctx),
+		Data:     input,
+	}
+
+	if len(input) <= unaryLimit {
+		resp, err := c.v2.SreeifyDocument(ctx, doc, grpc.WaitForReady(true))
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(resp.GetData())
+		return err
+	}
+
+	stream, err := c.v2.SreeifyDocumentStream(ctx, doc, grpc.WaitForReady(true))
+	if err != nil {
+		return err
+	}
+
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("receiving document: %w", err)
+		}
+		if _, err := w.Write(chunk.GetData()); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package ruleset
+
+// automaton is an Aho-Corasick automaton that finds every occurrence of a set of patterns in one
+// pass over the text. Matching ignores the case of ASCII letters.
+//
+// The automaton is compiled to a DFA over byte classes: bytes that don't appear in any pattern
+// share a class, so the transition table stays small however large the alphabet of the text.
+type automaton struct {
+	// classes maps each byte to its class. Upper and lower case ASCII letters share a class.
+	classes  [256]byte
+	nclasses int
+	// delta is the transition table, indexed by state*nclasses + class.
+	delta []int32
+	// out lists the patterns that end at each state.
+	out [][]int32
+	// lengths are the lengths of the patterns.
+	lengths []int
+}
+
+func newAutomaton(patterns []string) *automaton {
+	a := &automaton{nclasses: 1}
+
+	for _, p := range patterns {
+		for i := 0; i < len(p); i++ {
+			c := foldByte(p[i])
+			if a.classes[c] == 0 {
+				a.classes[c] = byte(a.nclasses)
+				a.nclasses++
+			}
+		}
+	}
+	for c := 'A'; c <= 'Z'; c++ {
+		a.classes[c] = a.classes[c+'a'-'A']
+	}
+
+	// Build the trie, with -1 for transitions that don't exist yet.
+	a.addState()
+	for id, p := range patterns {
+		state := int32(0)
+		for i := 0; i < len(p); i++ {
+			t := int(state)*a.nclasses + int(a.classes[p[i]])
+			if a.delta[t] < 0 {
+				next := a.addState()
+				a.delta[t] = next
+			}
+			state = a.delta[t]
+		}
+		a.out[state] = append(a.out[state], int32(id))
+		a.lengths = append(a.lengths, len(p))
+	}
+
+	// Breadth first, point each missing transition to where the state's longest proper suffix
+	// goes, and inherit the patterns that end at that suffix.
+	fail := make([]int32, len(a.out))
+	var queue []int32
+	for class := 0; class < a.nclasses; class++ {
+		next := &a.delta[class]
+		if *next < 0 {
+			*next = 0
+		} else {
+			queue = append(queue, *next)
+		}
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		a.out[state] = append(a.out[state], a.out[fail[state]]...)
+
+		for class := 0; class < a.nclasses; class++ {
+			next := &a.delta[int(state)*a.nclasses+class]
+			suffix := a.delta[int(fail[state])*a.nclasses+class]
+			if *next < 0 {
+				*next = suffix
+			} else {
+				fail[*next] = suffix
+				queue = append(queue, *next)
+			}
+		}
+	}
+
+	return a
+}
+
+func (a *automaton) addState() int32 {
+	for i := 0; i < a.nclasses; i++ {
+		a.delta = append(a.delta, -1)
+	}
+	a.out = append(a.out, nil)
+	return int32(len(a.out) - 1)
+}
+
+// find calls emit with the position of every occurrence of a pattern in s, including overlapping
+// ones.
+func (a *automaton) find(s string, emit func(start, end int, pattern int32)) {
+	state := int32(0)
+	for i := 0; i < len(s); i++ {
+		state = a.delta[int(state)*a.nclasses+int(a.classes[s[i]])]
+		for _, p := range a.out[state] {
+			emit(i+1-a.lengths[p], i+1, p)
+		}
+	}
+}
+
+func foldByte(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
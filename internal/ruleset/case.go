@@ -0,0 +1,214 @@
+package ruleset
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// transferCase returns replacement with the case pattern of matched. If both have the same number
+// of words, each word of the replacement takes the case of the matching word. Otherwise the
+// replacement takes the case of matched as a whole.
+func transferCase(matched, replacement string) string {
+	src, dst := words(matched), words(replacement)
+	if len(src) < 2 || len(src) != len(dst) {
+		return transferWord(matched, replacement)
+	}
+
+	var b strings.Builder
+	b.Grow(len(replacement))
+	last := 0
+	for i, w := range dst {
+		b.WriteString(replacement[last:w[0]])
+		b.WriteString(transferWord(matched[src[i][0]:src[i][1]], replacement[w[0]:w[1]]))
+		last = w[1]
+	}
+	b.WriteString(replacement[last:])
+	return b.String()
+}
+
+// transferWord returns dst with the case pattern of src. A word that is all upper case, all lower
+// case, title case or inverted title case, as in "wIKI", passes that on whole. Otherwise the case
+// is carried over letter by letter: the letters the words share, in order, take the case they have
+// in src, and the letters between them take the case of the letters between them in src at the
+// same relative position. Where several letters take the case of one upper case letter, only the
+// first is upper case unless the letter after it is too, so "WikiPedia" becomes "SreekiPedia"
+// rather than "SReekiPedia".
+func transferWord(src, dst string) string {
+	upper := letterCases(src)
+	switch {
+	case len(upper) == 0:
+		return dst
+	case len(upper) > 1 && !slices.Contains(upper, false):
+		return strings.ToUpper(dst)
+	case !slices.Contains(upper, true):
+		return strings.ToLower(dst)
+	case upper[0] && !slices.Contains(upper[1:], true):
+		return title(dst)
+	case len(upper) > 1 && !upper[0] && !slices.Contains(upper[1:], false):
+		return invertedTitle(dst)
+	}
+
+	out := []rune(dst)
+	var dstLetters []int
+	for i, r := range out {
+		if unicode.IsLetter(r) {
+			dstLetters = append(dstLetters, i)
+		}
+	}
+	from := alignLetters(letters(src), out, dstLetters)
+
+	for j, i := range dstLetters {
+		k := from[j]
+		up := upper[k]
+		if up && j > 0 && from[j-1] == k && k+1 < len(upper) && !upper[k+1] {
+			up = false
+		}
+		if up {
+			out[i] = unicode.ToUpper(out[i])
+		} else {
+			out[i] = unicode.ToLower(out[i])
+		}
+	}
+	return string(out)
+}
+
+// alignLetters returns, for each of the letters of dst at dstLetters, the index of the letter of
+// src whose case it takes. The longest sequence of letters the two share, ignoring case, is lined
+// up, and the letters between are spread over the letters between in src. Letters src has nothing
+// between for take the case of the letter before them, or after them at the start.
+func alignLetters(src, dst []rune, dstLetters []int) []int {
+	n, m := len(src), len(dstLetters)
+
+	// common[i][j] is the length of the longest common sequence of src[i:] and the letters from j.
+	common := make([][]int, n+1)
+	for i := range common {
+		common[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if equalFold(src[i], dst[dstLetters[j]]) {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	from := make([]int, m)
+	for j := range from {
+		from[j] = -1
+	}
+	for i, j := 0, 0; i < n && j < m; {
+		switch {
+		case equalFold(src[i], dst[dstLetters[j]]) && common[i][j] == common[i+1][j+1]+1:
+			from[j] = i
+			i++
+			j++
+		case common[i+1][j] >= common[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+
+	for j := 0; j < m; {
+		if from[j] >= 0 {
+			j++
+			continue
+		}
+		end := j
+		for end < m && from[end] < 0 {
+			end++
+		}
+		lo, hi := 0, n
+		if j > 0 {
+			lo = from[j-1] + 1
+		}
+		if end < m {
+			hi = from[end]
+		}
+		for g := j; g < end; g++ {
+			switch {
+			case hi > lo:
+				from[g] = lo + (g-j)*(hi-lo)/(end-j)
+			case lo > 0:
+				from[g] = lo - 1
+			default:
+				from[g] = 0
+			}
+		}
+		j = end
+	}
+	return from
+}
+
+// words returns the byte ranges of the runs of letters in s.
+func words(s string) [][2]int {
+	var ws [][2]int
+	start := -1
+	for i, r := range s {
+		switch {
+		case unicode.IsLetter(r) && start < 0:
+			start = i
+		case !unicode.IsLetter(r) && start >= 0:
+			ws = append(ws, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		ws = append(ws, [2]int{start, len(s)})
+	}
+	return ws
+}
+
+// letters returns the letters of s.
+func letters(s string) []rune {
+	var ls []rune
+	for _, r := range s {
+		if unicode.IsLetter(r) {
+			ls = append(ls, r)
+		}
+	}
+	return ls
+}
+
+// letterCases reports whether each letter of s is upper case. Letters without case count as lower
+// case.
+func letterCases(s string) []bool {
+	var cs []bool
+	for _, r := range s {
+		if unicode.IsLetter(r) {
+			cs = append(cs, unicode.IsUpper(r) || unicode.IsTitle(r))
+		}
+	}
+	return cs
+}
+
+// title returns s with its first letter in upper case and the rest in lower case.
+func title(s string) string {
+	out := []rune(strings.ToLower(s))
+	for i, r := range out {
+		if unicode.IsLetter(r) {
+			out[i] = unicode.ToUpper(r)
+			break
+		}
+	}
+	return string(out)
+}
+
+// invertedTitle returns s with its first letter lower case and the rest upper case.
+func invertedTitle(s string) string {
+	out := []rune(strings.ToUpper(s))
+	for i, r := range out {
+		if unicode.IsLetter(r) {
+			out[i] = unicode.ToLower(r)
+			break
+		}
+	}
+	return string(out)
+}
+
+func equalFold(a, b rune) bool {
+	return unicode.ToLower(a) == unicode.ToLower(b)
+}
@@ -0,0 +1,56 @@
+package ruleset
+
+import "testing"
+
+func TestTransferWord(t *testing.T) {
+	tests := []struct {
+		src, dst, want string
+	}{
+		{"wiki", "Sreeki", "sreeki"},
+		{"WIKI", "Sreeki", "SREEKI"},
+		{"Wiki", "sreeki", "Sreeki"},
+		{"W", "sreeki", "Sreeki"},
+		{"123", "Sreeki", "Sreeki"},
+		{"Wikimedia", "Sreekisreedia", "Sreekisreedia"},
+		// Mixed case is carried over letter by letter, lining up the letters the words share.
+		{"WikiPedia", "Sreekipedia", "SreekiPedia"},
+		{"MEDIAwiki", "Sreediasreeki", "SREEDIAsreeki"},
+		{"MediaWiki", "Sreediasreeki", "SreediaSreeki"},
+		// Inverted title case, as typed with caps lock on, is passed on whole.
+		{"wIKI", "Sreeki", "sREEKI"},
+		{"mEDIAWIKI", "sreediasreeki", "sREEDIASREEKI"},
+		{"wiKI", "sreeki", "sreeKI"},
+		{"WiKi", "Sreeki", "SreeKi"},
+		{"WikI", "sreeki", "SreekI"},
+		// Unsreefying takes fewer letters than it replaces.
+		{"SreekiPedia", "wikipedia", "WikiPedia"},
+		{"SREEDIAsreeki", "mediawiki", "MEDIAwiki"},
+	}
+	for _, tt := range tests {
+		if got := transferWord(tt.src, tt.dst); got != tt.want {
+			t.Errorf("transferWord(%q, %q) = %q, want %q", tt.src, tt.dst, got, tt.want)
+		}
+	}
+}
+
+func TestTransferCase(t *testing.T) {
+	tests := []struct {
+		matched, replacement, want string
+	}{
+		{"WikiPedia", "Sreekipedia", "SreekiPedia"},
+		// Each word takes the case of the word it replaces.
+		{"Free Encyclopedia", "sree encyclosreedia", "Sree Encyclosreedia"},
+		{"FREE encyclopedia", "sree encyclosreedia", "SREE encyclosreedia"},
+		{"free ENCYCLOPEDIA", "Sree Encyclosreedia", "sree ENCYCLOSREEDIA"},
+		{"wiki-Pedia", "sreeki pedia", "sreeki Pedia"},
+		// Matches with a different number of words than their replacement are taken whole.
+		{"Wiki Media", "sreekisreedia", "SreekiSreedia"},
+		{"WIKI MEDIA", "sreekisreedia", "SREEKISREEDIA"},
+		{"Free Wiki Media", "sree sreekisreedia", "Sree SreekiSreedia"},
+	}
+	for _, tt := range tests {
+		if got := transferCase(tt.matched, tt.replacement); got != tt.want {
+			t.Errorf("transferCase(%q, %q) = %q, want %q", tt.matched, tt.replacement, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package ruleset
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// current is the ruleset used to sreeify pages.
+var current atomic.Pointer[Ruleset]
+
+func init() {
+	current.Store(Default())
+}
+
+// Current returns the ruleset in use.
+func Current() *Ruleset {
+	return current.Load()
+}
+
+// Set replaces the ruleset in use.
+func Set(rs *Ruleset) {
+	current.Store(rs)
+}
+
+// Use loads the ruleset at path and puts it in use. If reload is positive, the file is checked for
+// changes that often and reloaded when it changes. A ruleset that fails to reload is logged and
+// the previous one is kept.
+func Use(path string, reload time.Duration) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("reading ruleset: %w", err)
+	}
+
+	rs, err := Load(path)
+	if err != nil {
+		return err
+	}
+	Set(rs)
+	slog.Info(fmt.Sprintf("Using ruleset %s from %s", rs.ID(), path))
+
+	if reload > 0 {
+		go watch(path, reload, info, nil)
+	}
+	return nil
+}
+
+// watch polls the ruleset at path, and reloads it when its size or modification time changes,
+// until stop is closed.
+func watch(path string, interval time.Duration, last os.FileInfo, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Failed to check ruleset %s: %v", path, err))
+			continue
+		}
+		if info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+			continue
+		}
+		last = info
+
+		rs, err := Load(path)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Failed to reload ruleset %s, keeping %s: %v", path, Current().ID(), err))
+			continue
+		}
+		if rs.ID() == Current().ID() {
+			continue
+		}
+		Set(rs)
+		slog.Info(fmt.Sprintf("Reloaded ruleset %s from %s", rs.ID(), path))
+	}
+}
@@ -0,0 +1,117 @@
+package ruleset
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// useCurrent restores the ruleset in use when the test ends.
+func useCurrent(t *testing.T) {
+	prev := Current()
+	t.Cleanup(func() { Set(prev) })
+}
+
+// writeRuleset replaces the file at path with data in one go, so the watcher can't read half of it.
+func writeRuleset(t *testing.T, path, data string) {
+	t.Helper()
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+const (
+	rulesV1 = "version: \"1\"\nrules:\n  - match: Wiki\n    replace: Sreeki\n"
+	rulesV2 = "version: \"2\"\nrules:\n  - match: Wiki\n    replace: Wooki\n  - match: Media\n    replace: Sreedia\n"
+)
+
+func TestUse(t *testing.T) {
+	useCurrent(t)
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	writeRuleset(t, path, rulesV1)
+
+	if err := Use(path, 0); err != nil {
+		t.Fatalf("Use() error = %v", err)
+	}
+	if got := Current().Sreefy("Wikipedia"); got != "Sreekipedia" {
+		t.Errorf("Sreefy() = %q with the loaded ruleset, want %q", got, "Sreekipedia")
+	}
+	if id := Current().ID(); id[:2] != "1-" {
+		t.Errorf("ID() = %q, want it to start with the version", id)
+	}
+}
+
+func TestUseRejectsInvalidRuleset(t *testing.T) {
+	useCurrent(t)
+	dir := t.TempDir()
+	prev := Current()
+
+	invalid := filepath.Join(dir, "invalid.yaml")
+	writeRuleset(t, invalid, "rules:\n  - match: Wiki\n    regex: Wiki\n")
+	for _, path := range []string{invalid, filepath.Join(dir, "missing.yaml")} {
+		if err := Use(path, 0); err == nil {
+			t.Errorf("Use(%s) succeeded", filepath.Base(path))
+		}
+		if Current() != prev {
+			t.Errorf("Use(%s) replaced the ruleset in use", filepath.Base(path))
+		}
+	}
+}
+
+func TestWatchReloads(t *testing.T) {
+	useCurrent(t)
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	writeRuleset(t, path, rulesV1)
+	rs, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Set(rs)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		watch(path, 5*time.Millisecond, info, stop)
+		close(done)
+	}()
+	defer func() {
+		close(stop)
+		<-done
+	}()
+
+	// waitFor waits for the ruleset in use to sreefy "Wiki" as want.
+	waitFor := func(want string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for Current().Sreefy("Wiki") != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("ruleset in use sreefies Wiki as %q, want %q", Current().Sreefy("Wiki"), want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	writeRuleset(t, path, rulesV2)
+	waitFor("Wooki")
+	reloaded := Current()
+
+	// A ruleset that fails to load is logged, and the previous one kept.
+	writeRuleset(t, path, "rules:\n  - match: [\n")
+	time.Sleep(50 * time.Millisecond)
+	if Current() != reloaded {
+		t.Error("an invalid ruleset replaced the one in use")
+	}
+
+	// It is picked up again once fixed.
+	writeRuleset(t, path, rulesV1)
+	waitFor("Sreeki")
+}
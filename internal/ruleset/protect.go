@@ -0,0 +1,204 @@
+package ruleset
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Protection keeps either text that matches a regular expression, or the contents of HTML elements
+// that match a selector, from being sreefied. Rules never match text that overlaps a protected
+// region, so nothing needs to be swapped out and back, and the region comes out byte for byte as
+// it went in.
+type Protection struct {
+	// Name describes what is protected.
+	Name string `yaml:"name"`
+	// Regex is a regular expression matching the protected text.
+	Regex string `yaml:"regex"`
+	// Selector is a simple CSS selector for the elements whose contents are protected: an element
+	// name followed by any number of .class, #id, [attr] and [attr=value] conditions.
+	Selector string `yaml:"selector"`
+}
+
+// protectedPattern is a compiled regex protection.
+type protectedPattern struct {
+	re *regexp.Regexp
+	// literal is text that every match contains, if there is any. Text without it can't match, so
+	// isn't searched.
+	literal string
+	// multiline is true if a match can span lines, or depends on where the text starts or ends.
+	// Otherwise only the lines containing literal are searched.
+	multiline bool
+}
+
+func (rs *Ruleset) compileProtections() error {
+	for i, p := range rs.Protect {
+		if (p.Regex == "") == (p.Selector == "") {
+			return fmt.Errorf("ruleset protection %d: exactly one of regex and selector is required", i+1)
+		}
+
+		if p.Selector != "" {
+			sel, err := parseSelector(p.Selector)
+			if err != nil {
+				return fmt.Errorf("ruleset protection %d: %w", i+1, err)
+			}
+			rs.selectors = append(rs.selectors, sel)
+			continue
+		}
+
+		re, err := regexp.Compile(p.Regex)
+		if err != nil {
+			return fmt.Errorf("ruleset protection %d: %w", i+1, err)
+		}
+		if re.MatchString("") {
+			return fmt.Errorf("ruleset protection %d: regex %q matches empty text", i+1, p.Regex)
+		}
+		parsed, err := syntax.Parse(p.Regex, syntax.Perl)
+		if err != nil {
+			return fmt.Errorf("ruleset protection %d: %w", i+1, err)
+		}
+		rs.protected = append(rs.protected, &protectedPattern{
+			re:        re,
+			literal:   requiredLiteral(parsed),
+			multiline: spansLines(parsed),
+		})
+	}
+	return nil
+}
+
+// requiredLiteral returns the longest literal text that every match of re contains, or "" if
+// there isn't any.
+func requiredLiteral(re *syntax.Regexp) string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase != 0 {
+			return ""
+		}
+		return string(re.Rune)
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiteral(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min > 0 {
+			return requiredLiteral(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		var longest string
+		for _, sub := range re.Sub {
+			if l := requiredLiteral(sub); len(l) > len(longest) {
+				longest = l
+			}
+		}
+		return longest
+	}
+	return ""
+}
+
+// spansLines reports whether a match of re can contain a newline, or is anchored to the start or
+// end of the text or a line, either of which would make it match differently on a single line.
+func spansLines(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpNoMatch, syntax.OpEmptyMatch, syntax.OpWordBoundary, syntax.OpNoWordBoundary, syntax.OpAnyCharNotNL:
+		return false
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			if r == '\n' {
+				return true
+			}
+		}
+		return false
+	case syntax.OpCharClass:
+		for i := 0; i < len(re.Rune); i += 2 {
+			if re.Rune[i] <= '\n' && '\n' <= re.Rune[i+1] {
+				return true
+			}
+		}
+		return false
+	case syntax.OpCapture, syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat, syntax.OpConcat, syntax.OpAlternate:
+		for _, sub := range re.Sub {
+			if spansLines(sub) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// find returns the byte ranges of the text in s that p matches.
+func (p *protectedPattern) find(s string) [][]int {
+	if p.literal == "" {
+		return p.re.FindAllStringIndex(s, -1)
+	}
+	if p.multiline {
+		if !strings.Contains(s, p.literal) {
+			return nil
+		}
+		return p.re.FindAllStringIndex(s, -1)
+	}
+
+	// Matches don't cross lines, so searching each line containing the literal finds the same
+	// ones as searching the whole text, without running the regex over the rest of it.
+	var regions [][]int
+	for from := 0; ; {
+		i := strings.Index(s[from:], p.literal)
+		if i < 0 {
+			return regions
+		}
+		i += from
+		start := strings.LastIndexByte(s[from:i], '\n') + 1 + from
+		end := len(s)
+		if j := strings.IndexByte(s[i:], '\n'); j >= 0 {
+			end = i + j
+		}
+		for _, r := range p.re.FindAllStringIndex(s[start:end], -1) {
+			regions = append(regions, []int{start + r[0], start + r[1]})
+		}
+		from = end
+	}
+}
+
+// protectedRegions returns the byte ranges of the text in s that the regex protections match,
+// sorted and merged so that none of them overlap.
+func (rs *Ruleset) protectedRegions(s string) [][]int {
+	var regions [][]int
+	for _, p := range rs.protected {
+		regions = append(regions, p.find(s)...)
+	}
+	if len(regions) < 2 {
+		return regions
+	}
+
+	sort.Slice(regions, func(i, j int) bool {
+		return regions[i][0] < regions[j][0]
+	})
+	merged := regions[:1]
+	for _, r := range regions[1:] {
+		last := merged[len(merged)-1]
+		if r[0] < last[1] {
+			last[1] = max(last[1], r[1])
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// Protects reports whether the contents of an HTML element are protected from being sreefied.
+func (rs *Ruleset) Protects(tag string, attrs []html.Attribute) bool {
+	for _, sel := range rs.selectors {
+		if sel.matches(tag, attrs) {
+			return true
+		}
+	}
+	return false
+}
+
+// ProtectsElements reports whether any HTML elements are protected, which callers can use to skip
+// collecting attributes for Protects.
+func (rs *Ruleset) ProtectsElements() bool {
+	return len(rs.selectors) > 0
+}
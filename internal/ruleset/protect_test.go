@@ -0,0 +1,65 @@
+package ruleset
+
+import (
+	"reflect"
+	"regexp/syntax"
+	"testing"
+)
+
+func TestSpansLines(t *testing.T) {
+	tests := []struct {
+		regex string
+		want  bool
+	}{
+		{`//[^\s"'<>]+`, false},
+		{`[\w.+-]+@[\w-]+(?:\.[\w-]+)+`, false},
+		{`\bwiki.*?\b`, false},
+		{`(?s)\{\{.*?\}\}`, true},
+		{`a\nb`, true},
+		{`[^x]+`, true},
+		{`^wiki`, true},
+		{`wiki$`, true},
+	}
+	for _, tt := range tests {
+		re, err := syntax.Parse(tt.regex, syntax.Perl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := spansLines(re); got != tt.want {
+			t.Errorf("spansLines(%q) = %t, want %t", tt.regex, got, tt.want)
+		}
+	}
+}
+
+func TestProtectedPatternFind(t *testing.T) {
+	s := "see https://en.wikipedia.org/wiki/Wiki\nor mail wiki@example.org, not https:\n{{Wiki\n}} //a //b"
+
+	rs := Default()
+	for _, p := range rs.protected {
+		// Searching only the lines with the literal on them finds what searching everything does.
+		want := p.re.FindAllStringIndex(s, -1)
+		if got := p.find(s); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: find() = %v, want %v", p.re, got, want)
+		}
+	}
+}
+
+func TestDefaultProtections(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"read https://en.wikipedia.org/wiki/Wiki on Wiki", "read https://en.wikipedia.org/wiki/Wiki on Sreeki"},
+		{"//upload.wikimedia.org/Wiki.png", "//upload.wikimedia.org/Wiki.png"},
+		{"mail wiki@wikimedia.org", "mail wiki@wikimedia.org"},
+		{"{{Wiki\nMedia}} Wiki", "{{Wiki\nMedia}} Sreeki"},
+		{`<span class="Wiki" data-wiki='Wiki'>Wiki</span>`, `<span class="Wiki" data-wiki='Wiki'>Sreeki</span>`},
+		{`<math><mi>Wiki</mi></math> Wiki`, `<math><mi>Wiki</mi></math> Sreeki`},
+	}
+
+	rs := Default()
+	for _, tt := range tests {
+		if got := rs.Sreefy(tt.in); got != tt.want {
+			t.Errorf("Sreefy(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package ruleset
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// replacer applies a list of rules in a single pass. Literal rules are found together by an
+// Aho-Corasick automaton, and regex rules by one regular expression with a capturing group per
+// rule. Where matches overlap, the one that starts first wins, then the longest, then the one of
+// the earliest rule.
+type replacer struct {
+	// literals finds the text of the literal rules.
+	literals *automaton
+	// owners are the rules each of the automaton's patterns belongs to.
+	owners [][]*compiledRule
+	// regex matches the regex rules. It is nil if there aren't any.
+	regex *regexp.Regexp
+	// groups are the regex rules, at the index of their capturing group.
+	groups []*compiledRule
+}
+
+type compiledRule struct {
+	// priority is the rule's position in the ruleset.
+	priority int
+	// match is a literal rule's text, and caseMode its case mode.
+	match    string
+	caseMode string
+	// wholeWord is true if a literal rule only matches whole words.
+	wholeWord bool
+	// keep is true if the matched text is left as it is.
+	keep bool
+	// forms maps each form a literal rule matches to its replacement, unless it matches any case.
+	forms map[string]string
+	// replace is the replacement of rules that match any case, and of regex rules.
+	replace string
+	// re is a regex rule's own expression, used to expand its replacement.
+	re *regexp.Regexp
+}
+
+// match is a match of a rule in the text.
+type match struct {
+	start, end int
+	rule       *compiledRule
+}
+
+func newReplacer(rules []*Rule) (*replacer, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	rp := &replacer{}
+	var patterns []string
+	patternIDs := make(map[string]int)
+	var alternatives []string
+	group := 1
+
+	for i, r := range rules {
+		cr := &compiledRule{
+			priority:  i,
+			match:     r.Match,
+			caseMode:  r.Case,
+			wholeWord: r.WholeWord,
+			keep:      r.Replace == nil,
+		}
+		if r.Replace != nil {
+			cr.replace = *r.Replace
+		}
+
+		if r.Regex != "" {
+			cr.re = regexp.MustCompile(r.Regex)
+			alternatives = append(alternatives, "("+r.Regex+")")
+			for len(rp.groups) < group {
+				rp.groups = append(rp.groups, nil)
+			}
+			rp.groups = append(rp.groups, cr)
+			group += 1 + cr.re.NumSubexp()
+			continue
+		}
+
+		if cr.caseMode == "" {
+			cr.caseMode = CasePreserve
+		}
+
+		var texts []string
+		if cr.foldsCase() {
+			texts = []string{r.Match, strings.ToLower(r.Match), strings.ToUpper(r.Match)}
+		} else {
+			cr.forms = make(map[string]string)
+			for _, f := range r.forms() {
+				if _, ok := cr.forms[f[0]]; !ok {
+					cr.forms[f[0]] = f[1]
+					texts = append(texts, f[0])
+				}
+			}
+		}
+
+		// The automaton ignores ASCII case, so forms that only differ in it are one pattern.
+		// Which of them matched is checked afterwards.
+		added := make(map[int]bool)
+		for _, text := range texts {
+			folded := foldASCII(text)
+			id, ok := patternIDs[folded]
+			if !ok {
+				id = len(patterns)
+				patternIDs[folded] = id
+				patterns = append(patterns, folded)
+				rp.owners = append(rp.owners, nil)
+			}
+			if !added[id] {
+				added[id] = true
+				rp.owners[id] = append(rp.owners[id], cr)
+			}
+		}
+	}
+
+	if len(patterns) > 0 {
+		rp.literals = newAutomaton(patterns)
+	}
+	if len(alternatives) > 0 {
+		var err error
+		rp.regex, err = regexp.Compile(strings.Join(alternatives, "|"))
+		if err != nil {
+			return nil, fmt.Errorf("compiling ruleset: %w", err)
+		}
+	}
+	return rp, nil
+}
+
+// forms returns the text a literal rule matches, with the replacement of each form.
+func (r *Rule) forms() [][2]string {
+	replace := r.Match
+	if r.Replace != nil {
+		replace = *r.Replace
+	}
+
+	forms := [][2]string{{r.Match, replace}}
+	if r.Case == CaseVariants {
+		forms = append(forms,
+			[2]string{strings.ToLower(r.Match), strings.ToLower(replace)},
+			[2]string{strings.ToUpper(r.Match), strings.ToUpper(replace)},
+		)
+	}
+	return forms
+}
+
+// matches returns the matches of the rules in s that are applied, in order. Matches that overlap
+// one of the protected regions of s are left out.
+func (rp *replacer) matches(s string, protect func(string) [][]int) []match {
+	var found []match
+
+	if rp.literals != nil {
+		rp.literals.find(s, func(start, end int, pattern int32) {
+			for _, cr := range rp.owners[pattern] {
+				if cr.matches(s, start, end) {
+					found = append(found, match{start, end, cr})
+				}
+			}
+		})
+	}
+
+	if rp.regex != nil {
+		for _, m := range rp.regex.FindAllStringSubmatchIndex(s, -1) {
+			for group, cr := range rp.groups {
+				if cr != nil && m[2*group] >= 0 {
+					found = append(found, match{m[0], m[1], cr})
+					break
+				}
+			}
+		}
+	}
+
+	if len(found) == 0 {
+		return nil
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		a, b := found[i], found[j]
+		if a.start != b.start {
+			return a.start < b.start
+		}
+		if a.end != b.end {
+			return a.end > b.end
+		}
+		return a.rule.priority < b.rule.priority
+	})
+
+	protected := protect(s)
+	applied := found[:0]
+	end := 0
+	for _, m := range found {
+		// Protected regions are sorted and don't overlap, so those that end before the match can't
+		// overlap any later match either.
+		for len(protected) > 0 && protected[0][1] <= m.start {
+			protected = protected[1:]
+		}
+		if len(protected) > 0 && protected[0][0] < m.end {
+			continue
+		}
+
+		if m.start >= end {
+			applied = append(applied, m)
+			end = m.end
+		}
+	}
+	return applied
+}
+
+// matches reports whether the literal rule matches s[start:end], which the automaton has found
+// ignoring ASCII case.
+func (cr *compiledRule) matches(s string, start, end int) bool {
+	text := s[start:end]
+	if cr.foldsCase() {
+		if !strings.EqualFold(text, cr.match) {
+			return false
+		}
+	} else if _, ok := cr.forms[text]; !ok {
+		return false
+	}
+
+	if cr.wholeWord {
+		if isWordByte(text[0]) && start > 0 && isWordByte(s[start-1]) {
+			return false
+		}
+		if isWordByte(text[len(text)-1]) && end < len(s) && isWordByte(s[end]) {
+			return false
+		}
+	}
+	return true
+}
+
+// foldsCase reports whether the literal rule matches its text in any case.
+func (cr *compiledRule) foldsCase() bool {
+	return cr.caseMode == CaseInsensitive || cr.caseMode == CasePreserve
+}
+
+// replacement returns the replacement of the text the rule matched.
+func (cr *compiledRule) replacement(matched string) string {
+	switch {
+	case cr.keep:
+		return matched
+	case cr.re != nil:
+		return string(cr.re.ExpandString(nil, cr.replace, matched, cr.re.FindStringSubmatchIndex(matched)))
+	case cr.forms != nil:
+		return cr.forms[matched]
+	case cr.caseMode == CasePreserve && matched != cr.match:
+		return transferCase(matched, cr.replace)
+	default:
+		return cr.replace
+	}
+}
+
+// replace applies the rules to s, outside the regions protect returns. If mark is true,
+// replacements are marked as spans.
+func (rp *replacer) replace(s string, mark bool, protect func(string) [][]int) string {
+	var matches []match
+	if rp != nil {
+		matches = rp.matches(s, protect)
+	}
+	if len(matches) == 0 && !(mark && strings.ContainsRune(s, spanStart)) {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	last := 0
+	for _, m := range matches {
+		original := s[m.start:m.end]
+		replacement := m.rule.replacement(original)
+
+		if !mark {
+			b.WriteString(s[last:m.start])
+			b.WriteString(replacement)
+		} else if replacement == original {
+			writeEscaped(&b, s[last:m.end])
+		} else {
+			writeEscaped(&b, s[last:m.start])
+			writeSpan(&b, original, replacement)
+		}
+		last = m.end
+	}
+
+	if mark {
+		writeEscaped(&b, s[last:])
+	} else {
+		b.WriteString(s[last:])
+	}
+	return b.String()
+}
+
+// isWordByte reports whether c is a word character: an ASCII letter, digit or underscore.
+func isWordByte(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+func foldASCII(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		b[i] = foldByte(c)
+	}
+	return string(b)
+}
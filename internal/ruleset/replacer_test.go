@@ -0,0 +1,147 @@
+package ruleset
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestSreefyOverlaps(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		// "free encyclopedia" is longer than "Free ", so it wins where they overlap.
+		{"Wikipedia, the free encyclopedia", "Sreekipedia, the Sree encyclopedia"},
+		{"The Free Encyclopedia", "The Sree Encyclopedia"},
+		{"FREE ENCYCLOPEDIA", "SREE ENCYCLOPEDIA"},
+		{"Free software", "Sree software"},
+		{"Free_content and Free<i>dom</i>", "Sree_content and Sree<i>dom</i>"},
+		// The keep rules for matchMedia and @media win over "Media", as they start first.
+		{"window.matchMedia(q)", "window.matchMedia(q)"},
+		{"@media screen", "@media screen"},
+		{"matchMediaWiki", "matchMediaSreeki"},
+		{"@mediawiki", "@mediasreeki"},
+		{"MediaWiki", "SreediaSreeki"},
+		{"socialmedia", "socialsreedia"},
+	}
+
+	rs := Default()
+	for _, tt := range tests {
+		if got := rs.Sreefy(tt.in); got != tt.want {
+			t.Errorf("Sreefy(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSreefyOverlapsIgnoreOrder(t *testing.T) {
+	rs, err := Parse([]byte(`
+rules:
+  - match: "Free "
+    replace: "Sree "
+  - match: Encyclopedia
+    replace: Encyclosreedia
+  - match: free encyclopedia
+    replace: Sree encyclopedia
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in, want := "the free encyclopedia", "the Sree encyclopedia"
+	if got := rs.Sreefy(in); got != want {
+		t.Errorf("Sreefy(%q) = %q, want %q", in, got, want)
+	}
+}
+
+func TestSreefyWholeWord(t *testing.T) {
+	rs, err := Parse([]byte(`
+rules:
+  - match: wiki
+    replace: sreeki
+    whole_word: true
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		in, want string
+	}{
+		{"wiki", "sreeki"},
+		{"a wiki.", "a sreeki."},
+		{"(WIKI)", "(SREEKI)"},
+		{"Wiki-page", "Sreeki-page"},
+		{"wikis", "wikis"},
+		{"MediaWiki", "MediaWiki"},
+		{"wiki_page", "wiki_page"},
+		{"wikiwiki wiki", "wikiwiki sreeki"},
+	}
+	for _, tt := range tests {
+		if got := rs.Sreefy(tt.in); got != tt.want {
+			t.Errorf("Sreefy(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// replaceAll sreefies s the way it was done before the ruleset engine: masking wikimedia.org URLs
+// with placeholders, then strings.ReplaceAll for each rule, as written, in lower case and in upper
+// case, putting back the identifiers that were wrongly replaced and finally the URLs. The URL
+// regex is compiled on every call, as it was.
+func replaceAll(rules []*Rule, s string) string {
+	urlPattern := regexp.MustCompile(`(//)((\w+)\.wikimedia.org)([\w\d+/_\-.%]*)["\s]`)
+	urlMatches := urlPattern.FindAllString(s, -1)
+	for i, m := range urlMatches {
+		s = strings.ReplaceAll(s, m, fmt.Sprintf("{{%d}}", i))
+	}
+
+	for _, r := range rules {
+		if r.Replace == nil {
+			continue
+		}
+		s = strings.ReplaceAll(s, r.Match, *r.Replace)
+		s = strings.ReplaceAll(s, strings.ToLower(r.Match), strings.ToLower(*r.Replace))
+		s = strings.ReplaceAll(s, strings.ToUpper(r.Match), strings.ToUpper(*r.Replace))
+	}
+	s = strings.ReplaceAll(s, "matchSreedia", "matchMedia")
+	s = strings.ReplaceAll(s, "@sreedia", "@media")
+
+	for i, m := range urlMatches {
+		s = strings.ReplaceAll(s, fmt.Sprintf("{{%d}}", i), m)
+	}
+	return s
+}
+
+// BenchmarkSreefy sreefies a 2 MB page made of copies of the main page in testdata: with
+// the built-in ruleset, with its rules alone, leaving out the regex protections, and with the
+// per-rule strings.ReplaceAll loop the rules replaced.
+func BenchmarkSreefy(b *testing.B) {
+	data, err := os.ReadFile("testdata/main_page.html")
+	if err != nil {
+		b.Fatal(err)
+	}
+	page := strings.Repeat(string(data), 2<<20/len(data)+1)[:2<<20]
+
+	rs := Default()
+	b.Run("ruleset", func(b *testing.B) {
+		b.SetBytes(int64(len(page)))
+		for i := 0; i < b.N; i++ {
+			rs.Sreefy(page)
+		}
+	})
+	rules := *rs
+	rules.protected = nil
+	b.Run("rules", func(b *testing.B) {
+		b.SetBytes(int64(len(page)))
+		for i := 0; i < b.N; i++ {
+			rules.Sreefy(page)
+		}
+	})
+	b.Run("replaceall", func(b *testing.B) {
+		b.SetBytes(int64(len(page)))
+		for i := 0; i < b.N; i++ {
+			replaceAll(rs.Rules, page)
+		}
+	})
+}
@@ -0,0 +1,124 @@
+package ruleset
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// In a reversible ruleset every replacement is marked as a span that carries the text it replaced:
+//
+//	spanStart, the original text as variation selectors, the replacement, spanEnd
+//
+// The markers and variation selectors are all default-ignorable, so browsers don't render them.
+// Unsreefy restores the original of each span and leaves the rest of the text alone, so text that
+// already looked sreefied survives the round trip. A spanStart in the original text is escaped as
+// a span with an empty replacement, so every spanStart in sreefied text begins a span.
+const (
+	spanStart = '\u2063' // INVISIBLE SEPARATOR
+	spanEnd   = '\u2064' // INVISIBLE PLUS
+)
+
+// writeSpan writes a span marking that original was replaced with replacement.
+func writeSpan(b *strings.Builder, original, replacement string) {
+	b.WriteRune(spanStart)
+	for i := 0; i < len(original); i++ {
+		b.WriteRune(byteSelector(original[i]))
+	}
+
+	// The replacement must not end the span early, or be read as part of the original.
+	replacement = strings.TrimLeftFunc(replacement, isSelector)
+	for _, r := range replacement {
+		if r != spanStart && r != spanEnd {
+			b.WriteRune(r)
+		}
+	}
+	b.WriteRune(spanEnd)
+}
+
+// writeEscaped writes text that wasn't replaced, escaping any span starts in it.
+func writeEscaped(b *strings.Builder, text string) {
+	for {
+		i := strings.IndexRune(text, spanStart)
+		if i < 0 {
+			b.WriteString(text)
+			return
+		}
+		b.WriteString(text[:i])
+		writeSpan(b, string(spanStart), "")
+		text = text[i+utf8.RuneLen(spanStart):]
+	}
+}
+
+// unmark replaces each span in s with the original text it carries.
+func unmark(s string) string {
+	if !strings.ContainsRune(s, spanStart) {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for {
+		i := strings.IndexRune(s, spanStart)
+		if i < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+		b.WriteString(s[:i])
+		s = s[i+utf8.RuneLen(spanStart):]
+
+		original, rest, ok := readSpan(s)
+		if !ok {
+			// Not a span after all, so the marker is kept as it is.
+			b.WriteRune(spanStart)
+			continue
+		}
+		b.WriteString(original)
+		s = rest
+	}
+}
+
+// readSpan reads the rest of a span from s, returning the original text it carries and the text
+// after it.
+func readSpan(s string) (original, rest string, ok bool) {
+	var buf []byte
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		c, ok := selectorByte(r)
+		if !ok {
+			break
+		}
+		buf = append(buf, c)
+		s = s[size:]
+	}
+
+	end := strings.IndexRune(s, spanEnd)
+	if len(buf) == 0 || end < 0 || strings.ContainsRune(s[:end], spanStart) {
+		return "", "", false
+	}
+	return string(buf), s[end+utf8.RuneLen(spanEnd):], true
+}
+
+// byteSelector returns the variation selector that encodes c. VS1 to VS16 encode 0 to 15, and
+// VS17 to VS256 encode 16 to 255.
+func byteSelector(c byte) rune {
+	if c < 16 {
+		return 0xFE00 + rune(c)
+	}
+	return 0xE0100 + rune(c) - 16
+}
+
+// selectorByte returns the byte encoded by the variation selector r.
+func selectorByte(r rune) (byte, bool) {
+	switch {
+	case 0xFE00 <= r && r <= 0xFE0F:
+		return byte(r - 0xFE00), true
+	case 0xE0100 <= r && r <= 0xE01EF:
+		return byte(r - 0xE0100 + 16), true
+	}
+	return 0, false
+}
+
+func isSelector(r rune) bool {
+	_, ok := selectorByte(r)
+	return ok
+}
@@ -0,0 +1,97 @@
+package ruleset
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// maxSeedSize is the size of the biggest fuzz seed. Longer lines of the test pages are cut short.
+const maxSeedSize = 512
+
+// reversibleDefault returns the built-in rules, made reversible.
+func reversibleDefault(t testing.TB) *Ruleset {
+	t.Helper()
+	rs, err := Parse(append(append([]byte{}, defaultRuleset...), "\nreversible: true\n"...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rs
+}
+
+// testPages returns the pages in testdata. Saved pages can be added there, such as with
+//
+//	curl -o testdata/Wikipedia.html https://en.wikipedia.org/wiki/Wikipedia
+//
+// and are round-tripped whole, and seed FuzzRoundTrip.
+func testPages(t testing.TB) map[string]string {
+	t.Helper()
+	paths, err := filepath.Glob("testdata/*.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages := make(map[string]string)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages[filepath.Base(path)] = string(data)
+	}
+	return pages
+}
+
+func TestRoundTripPages(t *testing.T) {
+	rs := reversibleDefault(t)
+	for name, page := range testPages(t) {
+		sreefied := rs.Sreefy(page)
+		if sreefied == page {
+			t.Errorf("%s: Sreefy() changed nothing", name)
+		}
+		if got := rs.Unsreefy(sreefied); got != page {
+			t.Errorf("%s: Unsreefy(Sreefy()) differs from the page", name)
+		}
+	}
+}
+
+// FuzzRoundTrip checks that unsreefying the output of a reversible ruleset gives back its input
+// exactly. It is seeded with the lines of the pages in testdata, text with span markers in it, and
+// text that already looks sreefied. Whole pages are too big for the fuzzer to mutate and minimize
+// at any useful rate, so they are round-tripped by TestRoundTripPages instead.
+func FuzzRoundTrip(f *testing.F) {
+	seen := make(map[string]bool)
+	for _, page := range testPages(f) {
+		for _, line := range strings.SplitAfter(page, "\n") {
+			if len(line) > maxSeedSize {
+				line = line[:maxSeedSize]
+			}
+			if !seen[line] {
+				seen[line] = true
+				f.Add(line)
+			}
+		}
+	}
+	for _, s := range []string{
+		"",
+		"⁣",
+		"Wiki⁣pedia",
+		"⁣︀⁤",
+		"⁣︀Sree⁤ Wiki ⁤",
+		"⁣\U000e0100",
+		"Sreekipedia, the Sree encyclopedia",
+		"SREEKI Sreedia Encyclosreedia Sree_content",
+		"Wikipedia, the free encyclopedia",
+		"window.matchMedia and @media",
+	} {
+		f.Add(s)
+	}
+
+	rs := reversibleDefault(f)
+	f.Fuzz(func(t *testing.T, s string) {
+		sreefied := rs.Sreefy(s)
+		if got := rs.Unsreefy(sreefied); got != s {
+			t.Errorf("Unsreefy(Sreefy(%q)) = %q, sreefied as %q", s, got, sreefied)
+		}
+	})
+}
@@ -0,0 +1,222 @@
+// Package ruleset loads the word replacement rules that sreeify text, and applies them in either
+// direction.
+package ruleset
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed default.yaml
+var defaultRuleset []byte
+
+// Case modes control which forms of a literal rule's text are replaced.
+const (
+	// CasePreserve matches the text in any case, and gives the replacement the case of the text it
+	// replaces, so "WIKI" becomes "SREEKI" and "MediaWiki" becomes "SreediaSreeki". Text as written
+	// is replaced with the replacement as written. It is the default.
+	CasePreserve = "preserve"
+	// CaseVariants matches the text as written, in lower case and in upper case, and replaces each
+	// with the same form of the replacement.
+	CaseVariants = "variants"
+	// CaseExact matches the text only as written.
+	CaseExact = "exact"
+	// CaseInsensitive matches the text in any case, and replaces it with the replacement as written.
+	CaseInsensitive = "insensitive"
+)
+
+// Ruleset is an ordered list of replacement rules. They are applied in a single pass over the text.
+// Where rules match overlapping text, the match that starts first is applied, then the longest,
+// then the one of the earliest rule.
+type Ruleset struct {
+	// Version identifies the rules. The ruleset's ID is made from it and a hash of the file.
+	Version string  `yaml:"version"`
+	Rules   []*Rule `yaml:"rules"`
+	// Reversible marks each replacement with the text it replaced, so that Unsreefy restores the
+	// original text exactly. See reversible.go.
+	Reversible bool `yaml:"reversible"`
+	// Protect lists the text that is never sreefied, nor unsreefied.
+	Protect []*Protection `yaml:"protect"`
+	// Messages lists the keys of the interface messages in scripts that are sreefied, such as
+	// "sitesubtitle". A key ending in "*" is a prefix, such as "vector-*".
+	Messages []string `yaml:"messages"`
+
+	id      string
+	forward *replacer
+	reverse *replacer
+	// protected and selectors are the compiled regex and selector protections.
+	protected []*protectedPattern
+	selectors []*selector
+}
+
+// Rule replaces either literal text or a regular expression.
+type Rule struct {
+	// Match is the literal text to replace.
+	Match string `yaml:"match"`
+	// Regex is a regular expression to replace, for rules that can't be written as literal text.
+	Regex string `yaml:"regex"`
+	// Replace is the replacement. Regex replacements may refer to submatches as in
+	// regexp.Regexp.Expand. If it's omitted the matched text is kept as it is.
+	Replace *string `yaml:"replace"`
+	// Case is the case mode of a literal rule.
+	Case string `yaml:"case"`
+	// WholeWord restricts a literal rule to text that isn't part of a longer word.
+	WholeWord bool `yaml:"whole_word"`
+	// Inverse is the rule that undoes a regex rule. Regex rules that replace text and have no inverse
+	// are left out when unsreefying. Literal rules are inverted by swapping Match and Replace.
+	Inverse *Rule `yaml:"inverse"`
+}
+
+// Default returns the built-in ruleset.
+func Default() *Ruleset {
+	rs, err := Parse(defaultRuleset)
+	if err != nil {
+		panic(err)
+	}
+	return rs
+}
+
+// Load reads a ruleset from a YAML or JSON file in the format of default.yaml.
+func Load(path string) (*Ruleset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading ruleset: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse parses and compiles a YAML or JSON ruleset.
+func Parse(data []byte) (*Ruleset, error) {
+	var rs Ruleset
+	if err := yaml.Unmarshal(data, &rs); err != nil {
+		return nil, fmt.Errorf("parsing ruleset: %w", err)
+	}
+
+	var inverse []*Rule
+	for i, r := range rs.Rules {
+		if err := r.validate(); err != nil {
+			return nil, fmt.Errorf("ruleset rule %d: %w", i+1, err)
+		}
+		if inv := r.inverse(); inv != nil {
+			inverse = append(inverse, inv)
+		}
+	}
+
+	if err := rs.compileProtections(); err != nil {
+		return nil, err
+	}
+	for i, key := range rs.Messages {
+		if key == "" || key == "*" || strings.Contains(strings.TrimSuffix(key, "*"), "*") {
+			return nil, fmt.Errorf("ruleset message %d: %q must be a key, or a prefix followed by *", i+1, key)
+		}
+	}
+
+	var err error
+	if rs.forward, err = newReplacer(rs.Rules); err != nil {
+		return nil, err
+	}
+	if rs.reverse, err = newReplacer(inverse); err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	rs.id = hex.EncodeToString(sum[:4])
+	if rs.Version != "" {
+		rs.id = rs.Version + "-" + rs.id
+	}
+	return &rs, nil
+}
+
+// ID identifies the ruleset's rules. It changes whenever the ruleset file does.
+func (rs *Ruleset) ID() string {
+	return rs.id
+}
+
+// Sreefy applies the rules to s.
+func (rs *Ruleset) Sreefy(s string) string {
+	return rs.forward.replace(s, rs.Reversible, rs.protectedRegions)
+}
+
+// Unsreefy reverses Sreefy. A reversible ruleset restores the original text exactly. Otherwise the
+// inverse of the rules is applied, which also changes text that looked sreefied to begin with.
+func (rs *Ruleset) Unsreefy(s string) string {
+	if rs.Reversible {
+		return unmark(s)
+	}
+	return rs.reverse.replace(s, false, rs.protectedRegions)
+}
+
+// SreefiesMessage reports whether the interface message with the key is sreefied.
+func (rs *Ruleset) SreefiesMessage(key string) bool {
+	for _, m := range rs.Messages {
+		if prefix, ok := strings.CutSuffix(m, "*"); ok && strings.HasPrefix(key, prefix) || key == m {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) validate() error {
+	if (r.Match == "") == (r.Regex == "") {
+		return fmt.Errorf("exactly one of match and regex is required")
+	}
+
+	if r.Match != "" {
+		switch r.Case {
+		case "", CasePreserve, CaseVariants, CaseExact, CaseInsensitive:
+		default:
+			return fmt.Errorf("unknown case mode: %s", r.Case)
+		}
+		if r.Inverse != nil {
+			return fmt.Errorf("literal rules are inverted automatically and can't have an inverse")
+		}
+		if r.Replace != nil && *r.Replace == "" {
+			return fmt.Errorf("literal rules can't replace text with nothing, as they couldn't be inverted")
+		}
+		return nil
+	}
+
+	if r.Case != "" || r.WholeWord {
+		return fmt.Errorf("case and whole_word only apply to literal rules, use (?i) and \\b instead")
+	}
+	re, err := regexp.Compile(r.Regex)
+	if err != nil {
+		return err
+	}
+	if re.MatchString("") {
+		return fmt.Errorf("regex %q matches empty text", r.Regex)
+	}
+	if r.Inverse != nil {
+		if r.Inverse.Regex == "" || r.Inverse.Inverse != nil {
+			return fmt.Errorf("the inverse of a regex rule must be a regex rule without an inverse")
+		}
+		if err := r.Inverse.validate(); err != nil {
+			return fmt.Errorf("inverse: %w", err)
+		}
+	}
+	return nil
+}
+
+// inverse returns the rule that undoes r, or nil if there isn't one.
+func (r *Rule) inverse() *Rule {
+	if r.Regex != "" {
+		if r.Replace == nil && r.Inverse == nil {
+			// Keeping text is its own inverse.
+			return r
+		}
+		return r.Inverse
+	}
+
+	inv := *r
+	if r.Replace != nil {
+		inv.Match, inv.Replace = *r.Replace, &r.Match
+	}
+	return &inv
+}
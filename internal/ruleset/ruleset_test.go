@@ -0,0 +1,65 @@
+package ruleset
+
+import "testing"
+
+func TestSreefiesMessage(t *testing.T) {
+	rs, err := Parse([]byte(`
+messages:
+  - sitesubtitle
+  - "vector-*"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"sitesubtitle", true},
+		{"vector-main-menu-label", true},
+		{"vector-", true},
+		{"sitesubtitle-extra", false},
+		{"content-type", false},
+		{"skin-name", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := rs.SreefiesMessage(tt.key); got != tt.want {
+			t.Errorf("SreefiesMessage(%q) = %t, want %t", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestParseMessages(t *testing.T) {
+	for _, key := range []string{`""`, `"*"`, `"vector-*-label"`, `"*-label"`} {
+		if _, err := Parse([]byte("messages:\n  - " + key + "\n")); err == nil {
+			t.Errorf("Parse() accepted message key %s", key)
+		}
+	}
+}
+
+func TestParseRejectsInvalidRules(t *testing.T) {
+	tests := []struct {
+		name, ruleset string
+	}{
+		{"invalid yaml", "rules: [\n"},
+		{"match and regex", "rules:\n  - match: Wiki\n    regex: Wiki\n"},
+		{"neither match nor regex", "rules:\n  - replace: Sreeki\n"},
+		{"unknown case mode", "rules:\n  - match: Wiki\n    replace: Sreeki\n    case: upper\n"},
+		{"empty replacement", "rules:\n  - match: Wiki\n    replace: \"\"\n"},
+		{"literal inverse", "rules:\n  - match: Wiki\n    replace: Sreeki\n    inverse:\n      regex: Sreeki\n"},
+		{"regex case mode", "rules:\n  - regex: Wiki\n    case: exact\n"},
+		{"invalid regex", "rules:\n  - regex: 'Wiki('\n"},
+		{"empty regex match", "rules:\n  - regex: 'x*'\n    replace: y\n"},
+		{"literal regex inverse", "rules:\n  - regex: Wiki\n    replace: Sreeki\n    inverse:\n      match: Sreeki\n"},
+		{"invalid protection regex", "protect:\n  - regex: '('\n"},
+		{"invalid selector", "protect:\n  - selector: 'div > p'\n"},
+	}
+
+	for _, tt := range tests {
+		if _, err := Parse([]byte(tt.ruleset)); err == nil {
+			t.Errorf("%s: Parse() accepted %q", tt.name, tt.ruleset)
+		}
+	}
+}
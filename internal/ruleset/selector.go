@@ -0,0 +1,134 @@
+package ruleset
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// selector is a simple CSS selector: an optional element name followed by any number of .class,
+// #id, [attr] and [attr=value] conditions, such as "span.texhtml" or "div[data-mw]".
+type selector struct {
+	tag     string
+	classes []string
+	attrs   []attrCondition
+}
+
+type attrCondition struct {
+	name, value string
+	// exists is true if the attribute only has to be present.
+	exists bool
+}
+
+// optionalEndTags are the elements whose end tag can be left out, as the next sibling or the end
+// of the parent closes them. Where they end can't be told without building the document tree, so
+// their contents can't be protected.
+var optionalEndTags = map[string]bool{
+	"li": true, "dt": true, "dd": true, "p": true, "rt": true, "rp": true, "optgroup": true,
+	"option": true, "colgroup": true, "caption": true, "thead": true, "tbody": true, "tfoot": true,
+	"tr": true, "td": true, "th": true,
+}
+
+// unsupported are the characters of the selector syntax that isn't supported, such as combinators
+// and pseudo-classes.
+const unsupported = " >+~*:,"
+
+func parseSelector(s string) (*selector, error) {
+	sel := &selector{}
+	rest := strings.TrimSpace(s)
+
+	i := strings.IndexAny(rest, ".#[")
+	if i < 0 {
+		i = len(rest)
+	}
+	sel.tag, rest = strings.ToLower(rest[:i]), rest[i:]
+
+	for rest != "" {
+		switch rest[0] {
+		case '.', '#':
+			end := strings.IndexAny(rest[1:], ".#[") + 1
+			if end == 0 {
+				end = len(rest)
+			}
+			name := rest[1:end]
+			if name == "" || strings.ContainsAny(name, unsupported) {
+				return nil, fmt.Errorf("selector %q: invalid name after %c", s, rest[0])
+			}
+			if rest[0] == '.' {
+				sel.classes = append(sel.classes, name)
+			} else {
+				sel.attrs = append(sel.attrs, attrCondition{name: "id", value: name})
+			}
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("selector %q: unterminated [", s)
+			}
+			name, value, ok := strings.Cut(rest[1:end], "=")
+			cond := attrCondition{name: strings.ToLower(strings.TrimSpace(name)), exists: !ok}
+			if ok {
+				cond.value = strings.Trim(strings.TrimSpace(value), `"'`)
+			}
+			if cond.name == "" {
+				return nil, fmt.Errorf("selector %q: missing attribute name", s)
+			}
+			sel.attrs = append(sel.attrs, cond)
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("selector %q: only element names, .class, #id and [attr] are supported", s)
+		}
+	}
+
+	if sel.tag == "" && len(sel.classes) == 0 && len(sel.attrs) == 0 {
+		return nil, fmt.Errorf("empty selector")
+	}
+	if strings.ContainsAny(sel.tag, unsupported) {
+		return nil, fmt.Errorf("selector %q: only element names, .class, #id and [attr] are supported", s)
+	}
+	if optionalEndTags[sel.tag] {
+		return nil, fmt.Errorf("selector %q: %s elements can't be protected, as their end tag is optional", s, sel.tag)
+	}
+	return sel, nil
+}
+
+// matches reports whether an element with the tag name and attributes matches the selector.
+// Elements with an optional end tag never match.
+func (sel *selector) matches(tag string, attrs []html.Attribute) bool {
+	if sel.tag != "" && sel.tag != tag || optionalEndTags[tag] {
+		return false
+	}
+
+	for _, class := range sel.classes {
+		if !hasClass(attrs, class) {
+			return false
+		}
+	}
+	for _, cond := range sel.attrs {
+		value, ok := attr(attrs, cond.name)
+		if !ok || !cond.exists && value != cond.value {
+			return false
+		}
+	}
+	return true
+}
+
+func attr(attrs []html.Attribute, name string) (string, bool) {
+	for _, a := range attrs {
+		if a.Key == name {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+func hasClass(attrs []html.Attribute, class string) bool {
+	classes, _ := attr(attrs, "class")
+	for _, c := range strings.Fields(classes) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
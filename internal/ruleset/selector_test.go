@@ -0,0 +1,52 @@
+package ruleset
+
+import (
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		selector string
+		ok       bool
+	}{
+		{"kbd", true},
+		{".mwe-math-element", true},
+		{"span.texhtml[data-mw]", true},
+		{"div#content", true},
+		{"", false},
+		{"div > p", false},
+		{"a:hover", false},
+		{"[data-mw", false},
+		// Elements with optional end tags can't be protected.
+		{"li", false},
+		{"P.lead", false},
+		{"td[colspan]", false},
+		{"dd", false},
+		{"option", false},
+	}
+
+	for _, tt := range tests {
+		_, err := parseSelector(tt.selector)
+		if (err == nil) != tt.ok {
+			t.Errorf("parseSelector(%q) error = %v, want ok %t", tt.selector, err, tt.ok)
+		}
+	}
+}
+
+func TestSelectorSkipsOptionalEndTags(t *testing.T) {
+	sel, err := parseSelector(".keep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	attrs := []html.Attribute{{Key: "class", Val: "keep"}}
+	if !sel.matches("span", attrs) {
+		t.Error(".keep doesn't match a span")
+	}
+	for _, tag := range []string{"li", "p", "td", "dd", "option"} {
+		if sel.matches(tag, attrs) {
+			t.Errorf(".keep matches a %s", tag)
+		}
+	}
+}
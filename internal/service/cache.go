@@ -0,0 +1,266 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/devhou-se/sreetcode/internal/config"
+	"github.com/devhou-se/sreetcode/internal/ruleset"
+)
+
+// Cache stores that can be selected in the config.
+const (
+	cacheStoreMemory = "memory"
+	cacheStoreDisk   = "disk"
+	cacheStoreNone   = "none"
+)
+
+// cacheHeader tells the client how the cache handled the request.
+const cacheHeader = "X-Cache"
+
+const (
+	cacheHit         = "HIT"
+	cacheMiss        = "MISS"
+	cacheRevalidated = "REVALIDATED"
+)
+
+// Without explicit freshness information, a response is considered fresh for a tenth of the time
+// since it was last modified, up to maxHeuristicFreshness.
+const maxHeuristicFreshness = 24 * time.Hour
+
+// cacheEntry is a sreeified page stored in the cache.
+type cacheEntry struct {
+	Status int
+	Header http.Header
+	Body   []byte
+
+	// ETag and LastModified are the upstream validators used to revalidate the entry.
+	ETag         string
+	LastModified string
+	// Expires is when the entry stops being fresh and has to be revalidated.
+	Expires time.Time
+}
+
+// clone returns a copy of e sharing its body, which is never modified.
+func (e *cacheEntry) clone() *cacheEntry {
+	c := *e
+	c.Header = e.Header.Clone()
+	return &c
+}
+
+// cacheStore is a place to keep cache entries. Implementations must be safe for concurrent use,
+// and must not let changes to an entry's fields or header, once set or returned, affect the stored
+// one. Bodies may be shared with the store, so are never modified.
+type cacheStore interface {
+	Get(key string) (*cacheEntry, bool)
+	Set(key string, e *cacheEntry)
+}
+
+// newCacheStore creates the cache store chosen in the config, or nil if caching is disabled.
+func newCacheStore(cfg config.Config) (cacheStore, error) {
+	switch cfg.CacheStore {
+	case cacheStoreMemory:
+		return newMemoryStore(cfg.CacheSize), nil
+	case cacheStoreDisk:
+		return newDiskStore(cfg.CacheDir, cfg.CacheSize)
+	case cacheStoreNone:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown cache store: %s", cfg.CacheStore)
+	}
+}
+
+// cacheKey identifies a sreeified page. Pages are re-sreeified when the rules change, so the
+// ruleset's ID is part of the key. So is the client's Accept-Language, which upstream uses to
+// choose between variants of some wikis, and the prefix of a mounted site, which its links are
+// rewritten under.
+func cacheKey(ur *upstreamRequest, acceptLanguage string) string {
+	return fmt.Sprintf("%s|%s|%s|%s|%s", ruleset.Current().ID(), ur.lang, acceptLanguage, ur.prefix, ur.url)
+}
+
+// cacheableRequest reports whether the response to r is the same for every client, so may be cached
+// and shared between concurrent requests. Anything else always goes upstream on its own.
+func cacheableRequest(r *http.Request) bool {
+	return r.Method == http.MethodGet && anonymousRequest(r)
+}
+
+// newCacheEntry creates a cache entry for a sreeified upstream response, or returns nil if the
+// response may not be stored. Responses marked private are only stored if private is true.
+func newCacheEntry(status int, header http.Header, body []byte, now time.Time, private bool) *cacheEntry {
+	if status != http.StatusOK {
+		return nil
+	}
+	if _, ok := parseCacheControl(header.Get("Cache-Control"))["private"]; ok && !private {
+		return nil
+	}
+
+	lifetime, ok := freshness(header, now)
+	if !ok {
+		return nil
+	}
+
+	e := &cacheEntry{
+		Status:       status,
+		Header:       header.Clone(),
+		Body:         body,
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+		Expires:      now.Add(lifetime),
+	}
+	e.Header.Del(cacheHeader)
+	// Cookies were set for the client that happened to miss the cache, not every client after it.
+	e.Header.Del("Set-Cookie")
+	e.Header.Set("Content-Length", strconv.Itoa(len(body)))
+
+	// An entry that is never fresh is only worth keeping if it can be revalidated.
+	if lifetime <= 0 && e.ETag == "" && e.LastModified == "" {
+		return nil
+	}
+	return e
+}
+
+// fresh reports whether the entry can be served without revalidation.
+func (e *cacheEntry) fresh(now time.Time) bool {
+	return now.Before(e.Expires)
+}
+
+// addValidators makes req conditional on the entry being out of date.
+func (e *cacheEntry) addValidators(h http.Header) {
+	if e.ETag != "" {
+		h.Set("If-None-Match", e.ETag)
+	}
+	if e.LastModified != "" {
+		h.Set("If-Modified-Since", e.LastModified)
+	}
+}
+
+// revalidated returns a copy of the entry refreshed by a 304 Not Modified response.
+func (e *cacheEntry) revalidated(h http.Header, now time.Time) *cacheEntry {
+	e2 := *e
+	if etag := h.Get("ETag"); etag != "" {
+		e2.ETag = etag
+	}
+	if lm := h.Get("Last-Modified"); lm != "" {
+		e2.LastModified = lm
+	}
+	lifetime, _ := freshness(h, now)
+	e2.Expires = now.Add(lifetime)
+	return &e2
+}
+
+// page returns the entry as a page to send to a client, marked with how the cache handled it.
+func (e *cacheEntry) page(status string) *page {
+	p := &page{
+		status:   e.Status,
+		header:   e.Header.Clone(),
+		body:     io.NopCloser(bytes.NewReader(e.Body)),
+		shared:   true,
+		compress: true,
+	}
+	p.header.Set(cacheHeader, status)
+	return p
+}
+
+// maxCachedBody is the size of the biggest page that is cached. Bigger pages are streamed without
+// keeping a copy.
+const maxCachedBody = 8 << 20
+
+// cachingReader passes a page body through, keeping a copy to cache once it has all been read, as
+// long as it's no bigger than maxCachedBody.
+type cachingReader struct {
+	io.ReadCloser
+	buf  bytes.Buffer
+	done func(body []byte)
+}
+
+func (r *cachingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if r.done != nil {
+		r.buf.Write(p[:n])
+		if r.buf.Len() > maxCachedBody {
+			r.buf, r.done = bytes.Buffer{}, nil
+		}
+	}
+	if err == io.EOF && r.done != nil {
+		r.done(r.buf.Bytes())
+		r.done = nil
+	}
+	return n, err
+}
+
+// freshness works out how long a response may be served from the cache without revalidation, and
+// whether it may be stored at all, following its Cache-Control, Expires and Last-Modified headers.
+//
+// Responses marked private are revalidated every time, if they're stored at all: requests with
+// credentials bypass the cache, so what upstream returns shouldn't be specific to a user, but the
+// config can turn storing them off. Wikipedia marks all of its pages private.
+func freshness(h http.Header, now time.Time) (time.Duration, bool) {
+	cc := parseCacheControl(h.Get("Cache-Control"))
+	if _, ok := cc["no-store"]; ok {
+		return 0, false
+	}
+	if _, ok := cc["no-cache"]; ok {
+		return 0, true
+	}
+	if _, ok := cc["private"]; ok {
+		return 0, true
+	}
+
+	age := time.Duration(0)
+	if a, err := strconv.Atoi(h.Get("Age")); err == nil {
+		age = time.Duration(a) * time.Second
+	}
+
+	for _, directive := range []string{"s-maxage", "max-age"} {
+		if v, ok := cc[directive]; ok {
+			secs, err := strconv.Atoi(v)
+			if err != nil {
+				return 0, true
+			}
+			return time.Duration(secs)*time.Second - age, true
+		}
+	}
+
+	date := now
+	if d, err := http.ParseTime(h.Get("Date")); err == nil {
+		date = d
+	}
+
+	if v := h.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			// An invalid Expires means the response has already expired.
+			return 0, true
+		}
+		return expires.Sub(date) - age, true
+	}
+
+	if lm, err := http.ParseTime(h.Get("Last-Modified")); err == nil {
+		lifetime := date.Sub(lm) / 10
+		if lifetime > maxHeuristicFreshness {
+			lifetime = maxHeuristicFreshness
+		}
+		return lifetime - age, true
+	}
+
+	return 0, true
+}
+
+// parseCacheControl splits a Cache-Control header into its directives.
+func parseCacheControl(v string) map[string]string {
+	cc := make(map[string]string)
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(part, "=")
+		cc[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+	return cc
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// lru tracks the least recently used of a set of sized items, evicting them once the total size
+// passes maxSize. It isn't safe for concurrent use.
+type lru struct {
+	maxSize int
+	size    int
+	ll      *list.List
+	items   map[string]*list.Element
+}
+
+type lruItem struct {
+	key   string
+	value any
+	size  int
+}
+
+func newLRU(maxSize int) *lru {
+	return &lru{
+		maxSize: maxSize,
+		ll:      list.New(),
+		items:   make(map[string]*list.Element),
+	}
+}
+
+// get returns the value of key, marking it as the most recently used.
+func (l *lru) get(key string) (any, bool) {
+	el, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	l.ll.MoveToFront(el)
+	return el.Value.(*lruItem).value, true
+}
+
+// add stores key as the most recently used item, replacing any item with the same key, and
+// returns the keys evicted to make room for it. Items bigger than maxSize aren't stored.
+func (l *lru) add(key string, value any, size int) []string {
+	if size > l.maxSize {
+		return nil
+	}
+
+	l.remove(key)
+	l.items[key] = l.ll.PushFront(&lruItem{key: key, value: value, size: size})
+	l.size += size
+
+	var evicted []string
+	for l.size > l.maxSize {
+		item := l.ll.Back().Value.(*lruItem)
+		l.remove(item.key)
+		evicted = append(evicted, item.key)
+	}
+	return evicted
+}
+
+// remove forgets key, if it's stored.
+func (l *lru) remove(key string) {
+	el, ok := l.items[key]
+	if !ok {
+		return
+	}
+	l.ll.Remove(el)
+	delete(l.items, key)
+	l.size -= el.Value.(*lruItem).size
+}
+
+// memoryStore is an in-memory LRU cache store, bounded by the total size of the entries it holds.
+type memoryStore struct {
+	mu      sync.Mutex
+	entries *lru
+}
+
+func newMemoryStore(maxSize int) *memoryStore {
+	return &memoryStore{entries: newLRU(maxSize)}
+}
+
+func (s *memoryStore) Get(key string) (*cacheEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.entries.get(key)
+	if !ok {
+		return nil, false
+	}
+	return v.(*cacheEntry).clone(), true
+}
+
+func (s *memoryStore) Set(key string, e *cacheEntry) {
+	e = e.clone()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries.add(key, e, entrySize(e))
+}
+
+// entrySize approximates the memory used by an entry.
+func entrySize(e *cacheEntry) int {
+	size := len(e.Body) + len(e.ETag) + len(e.LastModified)
+	for h, values := range e.Header {
+		for _, v := range values {
+			size += len(h) + len(v)
+		}
+	}
+	return size
+}
+
+// tempPrefix starts the names of entries being written to a diskStore.
+const tempPrefix = "tmp-"
+
+// diskStore is a cache store that keeps each entry in its own file under a directory. Like
+// memoryStore, it is an LRU bounded by the total size of its files. Entries from a previous run
+// are kept, and are the first to be evicted.
+type diskStore struct {
+	dir string
+
+	// mu guards files, and the files in dir being replaced or removed.
+	mu    sync.Mutex
+	files *lru
+}
+
+func newDiskStore(dir string, maxSize int) (*diskStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating cache directory: %w", err)
+	}
+	s := &diskStore{dir: dir, files: newLRU(maxSize)}
+
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading cache directory: %w", err)
+	}
+	var infos []fs.FileInfo
+	for _, de := range dirEntries {
+		if !de.Type().IsRegular() {
+			continue
+		}
+		if strings.HasPrefix(de.Name(), tempPrefix) {
+			// Left by a run that stopped while writing an entry.
+			os.Remove(filepath.Join(dir, de.Name()))
+			continue
+		}
+		if info, err := de.Info(); err == nil {
+			infos = append(infos, info)
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ModTime().Before(infos[j].ModTime())
+	})
+	for _, info := range infos {
+		s.add(info.Name(), int(info.Size()))
+	}
+
+	return s, nil
+}
+
+// name returns the name of the file an entry is kept in. Keys are hashed as they contain URLs.
+func (s *diskStore) name(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// add tracks the file name, removing the files evicted to make room for it, or the file itself if
+// it's too big to keep. s.mu must be held.
+func (s *diskStore) add(name string, size int) {
+	if size > s.files.maxSize {
+		s.files.remove(name)
+		os.Remove(filepath.Join(s.dir, name))
+		return
+	}
+	for _, evicted := range s.files.add(name, nil, size) {
+		if err := os.Remove(filepath.Join(s.dir, evicted)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			slog.Error(fmt.Sprintf("Error removing cache entry: %s", err))
+		}
+	}
+}
+
+func (s *diskStore) Get(key string) (*cacheEntry, bool) {
+	name := s.name(key)
+
+	s.mu.Lock()
+	_, ok := s.files.get(name)
+	s.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	// An entry evicted from here on is still read whole, as removing it doesn't affect open files.
+	f, err := os.Open(filepath.Join(s.dir, name))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.Error(fmt.Sprintf("Error opening cache entry: %s", err))
+		}
+		return nil, false
+	}
+	defer f.Close()
+
+	var e cacheEntry
+	if err := gob.NewDecoder(f).Decode(&e); err != nil {
+		slog.Error(fmt.Sprintf("Error reading cache entry: %s", err))
+		return nil, false
+	}
+	return &e, true
+}
+
+func (s *diskStore) Set(key string, e *cacheEntry) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(e); err != nil {
+		slog.Error(fmt.Sprintf("Error encoding cache entry: %s", err))
+		return
+	}
+	if buf.Len() > s.files.maxSize {
+		return
+	}
+
+	// Write to a temporary file and rename it into place, so readers never see a partial entry.
+	f, err := os.CreateTemp(s.dir, tempPrefix)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Error creating cache entry: %s", err))
+		return
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(buf.Bytes())
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		slog.Error(fmt.Sprintf("Error writing cache entry: %s", err))
+		return
+	}
+
+	name := s.name(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Rename(f.Name(), filepath.Join(s.dir, name)); err != nil {
+		slog.Error(fmt.Sprintf("Error writing cache entry: %s", err))
+		return
+	}
+	s.add(name, buf.Len())
+}
@@ -0,0 +1,250 @@
+package service
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFreshness(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	date := now.Format(http.TimeFormat)
+
+	tests := []struct {
+		name     string
+		header   http.Header
+		lifetime time.Duration
+		store    bool
+	}{
+		{"no headers", http.Header{}, 0, true},
+		{"no-store", http.Header{"Cache-Control": {"no-store, max-age=60"}}, 0, false},
+		{"no-cache", http.Header{"Cache-Control": {"no-cache, max-age=60"}}, 0, true},
+		{"private", http.Header{"Cache-Control": {"private, max-age=60"}}, 0, true},
+		{"max-age", http.Header{"Cache-Control": {"max-age=60"}}, time.Minute, true},
+		{"s-maxage wins", http.Header{"Cache-Control": {"max-age=60, s-maxage=120"}}, 2 * time.Minute, true},
+		{"age", http.Header{"Cache-Control": {"max-age=60"}, "Age": {"20"}}, 40 * time.Second, true},
+		{"invalid max-age", http.Header{"Cache-Control": {"max-age=soon"}}, 0, true},
+		{"expires", http.Header{"Date": {date}, "Expires": {now.Add(time.Hour).Format(http.TimeFormat)}}, time.Hour, true},
+		{"invalid expires", http.Header{"Date": {date}, "Expires": {"0"}}, 0, true},
+		{
+			"heuristic",
+			http.Header{"Date": {date}, "Last-Modified": {now.Add(-10 * time.Hour).Format(http.TimeFormat)}},
+			time.Hour,
+			true,
+		},
+		{
+			"heuristic limit",
+			http.Header{"Date": {date}, "Last-Modified": {now.Add(-100 * 24 * time.Hour).Format(http.TimeFormat)}},
+			maxHeuristicFreshness,
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		lifetime, store := freshness(tt.header, now)
+		if lifetime != tt.lifetime || store != tt.store {
+			t.Errorf("%s: freshness() = %s, %t; want %s, %t", tt.name, lifetime, store, tt.lifetime, tt.store)
+		}
+	}
+}
+
+func TestNewCacheEntryPrivate(t *testing.T) {
+	now := time.Now()
+	header := http.Header{"Cache-Control": {"private, must-revalidate, max-age=0"}, "Etag": {`"1"`}}
+
+	if e := newCacheEntry(http.StatusOK, header, []byte("page"), now, false); e != nil {
+		t.Error("newCacheEntry() stored a private response without being allowed to")
+	}
+	e := newCacheEntry(http.StatusOK, header, []byte("page"), now, true)
+	if e == nil {
+		t.Fatal("newCacheEntry() didn't store a private response it was allowed to")
+	}
+	if e.fresh(now) {
+		t.Error("private entry is fresh, want it revalidated every time")
+	}
+}
+
+func TestCacheEntryRevalidated(t *testing.T) {
+	now := time.Now()
+	e := &cacheEntry{
+		Status:       http.StatusOK,
+		Header:       http.Header{"Content-Type": {"text/html"}},
+		Body:         []byte("page"),
+		ETag:         `"1"`,
+		LastModified: "Sat, 01 Jun 2024 12:00:00 GMT",
+		Expires:      now.Add(-time.Minute),
+	}
+
+	got := e.revalidated(http.Header{"Etag": {`"2"`}, "Cache-Control": {"max-age=60"}}, now)
+	if got.ETag != `"2"` || got.LastModified != e.LastModified {
+		t.Errorf("revalidated() validators = %q, %q; want %q, %q", got.ETag, got.LastModified, `"2"`, e.LastModified)
+	}
+	if !got.Expires.Equal(now.Add(time.Minute)) {
+		t.Errorf("revalidated() expires %s, want %s", got.Expires, now.Add(time.Minute))
+	}
+	if string(got.Body) != "page" || got.Header.Get("Content-Type") != "text/html" {
+		t.Error("revalidated() changed the stored page")
+	}
+	if e.ETag != `"1"` || e.fresh(now) {
+		t.Error("revalidated() changed the original entry")
+	}
+}
+
+// testEntry returns an entry whose body is size bytes.
+func testEntry(size int) *cacheEntry {
+	return &cacheEntry{Status: http.StatusOK, Body: make([]byte, size)}
+}
+
+func TestMemoryStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	s := newMemoryStore(300)
+	s.Set("a", testEntry(100))
+	s.Set("b", testEntry(100))
+	s.Set("c", testEntry(100))
+
+	// Using a makes b the least recently used.
+	if _, ok := s.Get("a"); !ok {
+		t.Fatal("a wasn't stored")
+	}
+	s.Set("d", testEntry(100))
+
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true, "d": true} {
+		if _, ok := s.Get(key); ok != want {
+			t.Errorf("Get(%q) found %t, want %t", key, ok, want)
+		}
+	}
+
+	// Entries bigger than the whole store aren't kept.
+	s.Set("e", testEntry(1000))
+	if _, ok := s.Get("e"); ok {
+		t.Error("entry bigger than the store was kept")
+	}
+}
+
+func TestMemoryStoreCopiesEntries(t *testing.T) {
+	s := newMemoryStore(1000)
+	set := &cacheEntry{Status: http.StatusOK, Header: http.Header{"Content-Type": {"text/html"}}, ETag: `"1"`}
+	s.Set("a", set)
+	set.Header.Set("Content-Type", "text/plain")
+
+	e, _ := s.Get("a")
+	e.ETag = `"2"`
+	e.Header.Set("X-Cache", "HIT")
+	e, _ = s.Get("a")
+	if e.ETag != `"1"` {
+		t.Error("changing a returned entry changed the stored one")
+	}
+	want := http.Header{"Content-Type": {"text/html"}}
+	if !reflect.DeepEqual(e.Header, want) {
+		t.Errorf("stored header = %v, want %v: changes to the entry's header reached the store", e.Header, want)
+	}
+}
+
+// files returns the names of the cache entries in dir.
+func files(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestDiskStore(t *testing.T) {
+	dir := t.TempDir()
+	s, err := newDiskStore(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &cacheEntry{
+		Status:  http.StatusOK,
+		Header:  http.Header{"Content-Type": {"text/html"}},
+		Body:    []byte("<p>Sreekipedia</p>"),
+		ETag:    `"1"`,
+		Expires: time.Now().Add(time.Hour).Round(0),
+	}
+	s.Set("page", want)
+
+	got, ok := s.Get("page")
+	if !ok {
+		t.Fatal("Get() didn't find the stored entry")
+	}
+	if got.Status != want.Status || string(got.Body) != string(want.Body) || got.ETag != want.ETag ||
+		got.Header.Get("Content-Type") != "text/html" || !got.Expires.Equal(want.Expires) {
+		t.Errorf("Get() = %+v, want %+v", got, want)
+	}
+	if _, ok := s.Get("other"); ok {
+		t.Error("Get() found an entry that wasn't stored")
+	}
+
+	// Entries are kept across restarts, and nothing but entries is left in the directory.
+	if err := os.WriteFile(filepath.Join(dir, tempPrefix+"1"), []byte("partial"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err = newDiskStore(dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Get("page"); !ok {
+		t.Error("entry wasn't kept across restarts")
+	}
+	if names := files(t, dir); len(names) != 1 {
+		t.Errorf("cache directory holds %v, want a single entry", names)
+	}
+}
+
+func TestDiskStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	// Size the store to hold three entries.
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(testEntry(1000)); err != nil {
+		t.Fatal(err)
+	}
+	maxSize := 3*buf.Len() + buf.Len()/2
+
+	dir := t.TempDir()
+	s, err := newDiskStore(dir, maxSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		s.Set(fmt.Sprint(i), testEntry(1000))
+	}
+	// Using 0 makes 1 the least recently used.
+	if _, ok := s.Get("0"); !ok {
+		t.Fatal("0 wasn't stored")
+	}
+	s.Set("3", testEntry(1000))
+
+	for key, want := range map[string]bool{"0": true, "1": false, "2": true, "3": true} {
+		if _, ok := s.Get(key); ok != want {
+			t.Errorf("Get(%q) found %t, want %t", key, ok, want)
+		}
+	}
+	if names := files(t, dir); len(names) != 3 {
+		t.Errorf("cache directory holds %d files, want 3", len(names))
+	}
+
+	// The oldest entries of a previous run are evicted first.
+	s, err = newDiskStore(dir, maxSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Set("4", testEntry(1000))
+	if names := files(t, dir); len(names) != 3 {
+		t.Errorf("cache directory holds %d files after a restart, want 3", len(names))
+	}
+	if _, ok := s.Get("4"); !ok {
+		t.Error("newest entry was evicted")
+	}
+}
@@ -0,0 +1,308 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// proxyMetrics counts upstream fetches and how many requests shared another request's fetch.
+var proxyMetrics = expvar.NewMap("proxy")
+
+// errNotShared is given to requests that joined a fetch whose page turned out not to be shareable.
+var errNotShared = errors.New("page can't be shared")
+
+// A shared fetch doesn't stop when the request that started it goes away, so it has its own limits:
+// it fails if the page isn't ready within sharedFetchTimeout, or upstream sends nothing of the body
+// for sharedReadTimeout.
+const (
+	sharedFetchTimeout = time.Minute
+	sharedReadTimeout  = 30 * time.Second
+)
+
+// flightGroup shares the result of an upstream fetch between concurrent requests for the same page.
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+
+	fetchTimeout time.Duration
+	readTimeout  time.Duration
+}
+
+// flight is an upstream fetch in progress.
+type flight struct {
+	// ready is closed once the response headers are known, or the fetch has failed.
+	ready  chan struct{}
+	status int
+	header http.Header
+	body   *sharedBody
+	// compress is copied from the fetched page.
+	compress bool
+	err      error
+}
+
+func newFlightGroup() *flightGroup {
+	return &flightGroup{
+		flights:      make(map[string]*flight),
+		fetchTimeout: sharedFetchTimeout,
+		readTimeout:  sharedReadTimeout,
+	}
+}
+
+// page returns a copy of the flight's page for one client, or false if the start of the body has
+// already been dropped.
+func (f *flight) page() (*page, bool) {
+	body, ok := f.body.reader()
+	if !ok {
+		return nil, false
+	}
+	return &page{
+		status:   f.status,
+		header:   f.header.Clone(),
+		body:     body,
+		shared:   true,
+		compress: f.compress,
+	}, true
+}
+
+// fetchShared fetches a page like fetch, but joins a fetch for the same page if one is already in
+// progress. The fetch carries on even if the request that started it goes away, so the others
+// waiting on it still get the page, and it is cached for the next one. It is bounded by the
+// group's timeouts instead, so a stalled upstream can't hold up every request for the page.
+func (s *Server) fetchShared(ctx context.Context, ur *upstreamRequest) (*page, error) {
+	g := s.inflight
+
+	g.mu.Lock()
+	f, ok := g.flights[ur.key]
+	if !ok {
+		f = &flight{ready: make(chan struct{})}
+		g.flights[ur.key] = f
+	}
+	g.mu.Unlock()
+
+	if ok {
+		proxyMetrics.Add("coalesced_requests", 1)
+
+		select {
+		case <-f.ready:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		if errors.Is(f.err, errNotShared) {
+			return s.fetch(ctx, ur)
+		}
+		if f.err != nil {
+			return nil, f.err
+		}
+		p, ok := f.page()
+		if !ok {
+			return s.fetch(ctx, ur)
+		}
+		// Cookies are set for the request that started the fetch, not the ones that joined it.
+		p.header.Del("Set-Cookie")
+		return p, nil
+	}
+
+	land := func() {
+		g.mu.Lock()
+		if g.flights[ur.key] == f {
+			delete(g.flights, ur.key)
+		}
+		g.mu.Unlock()
+	}
+
+	fctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	timer := time.AfterFunc(g.fetchTimeout, cancel)
+
+	p, err := s.fetch(fctx, ur)
+	timer.Stop()
+	switch {
+	case err != nil:
+		f.err = err
+		cancel()
+	case !p.shared:
+		// Nobody else reads the page, so it's fetched for this request alone after all.
+		f.err = errNotShared
+		stop := context.AfterFunc(ctx, cancel)
+		p.body = &cancelCloser{ReadCloser: p.body, cancel: func() {
+			stop()
+			cancel()
+		}}
+	default:
+		body := &idleReader{ReadCloser: p.body, timer: timer, timeout: g.readTimeout}
+		// Requests keep joining the flight until the whole page has been read, or it is too big to
+		// keep.
+		f.status, f.header, f.body, f.compress = p.status, p.header, newSharedBody(land), p.compress
+		p, _ = f.page()
+		go func() {
+			defer cancel()
+			f.body.fill(body)
+			land()
+		}()
+	}
+	if f.body == nil {
+		land()
+	}
+	close(f.ready)
+
+	return p, err
+}
+
+// cancelCloser cancels a fetch's context once its body is closed.
+type cancelCloser struct {
+	io.ReadCloser
+	cancel func()
+}
+
+func (c *cancelCloser) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// idleReader fails reads that wait longer than timeout for data, by letting timer cancel the fetch
+// the body belongs to.
+type idleReader struct {
+	io.ReadCloser
+	timer   *time.Timer
+	timeout time.Duration
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	r.timer.Reset(r.timeout)
+	n, err := r.ReadCloser.Read(p)
+	r.timer.Stop()
+	return n, err
+}
+
+// maxSharedBody is how much of a page a sharedBody keeps. Past that, the part every client has read
+// is dropped and no more clients can join, and reading upstream waits for the slowest client.
+const maxSharedBody = 8 << 20
+
+// sharedBody is a page body that is read from upstream once, and written to every client waiting
+// on it as it arrives.
+type sharedBody struct {
+	mu sync.Mutex
+	// cond is broadcast when the body grows and when a client reads from it.
+	cond *sync.Cond
+	// buf holds the body from offset base on.
+	buf     []byte
+	base    int
+	readers map[*sharedReader]bool
+	done    bool
+	err     error
+
+	// full is called once the body has outgrown maxSharedBody.
+	full     func()
+	fullOnce sync.Once
+}
+
+func newSharedBody(full func()) *sharedBody {
+	b := &sharedBody{readers: make(map[*sharedReader]bool), full: full}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// fill reads r into the body until it ends.
+func (b *sharedBody) fill(r io.ReadCloser) {
+	defer r.Close()
+
+	chunk := make([]byte, copyBufferSize)
+	for {
+		n, err := r.Read(chunk)
+
+		b.mu.Lock()
+		b.buf = append(b.buf, chunk[:n]...)
+		if err != nil {
+			b.done = true
+			if err != io.EOF {
+				b.err = err
+			}
+		}
+		b.cond.Broadcast()
+		over := len(b.buf) > maxSharedBody
+		b.mu.Unlock()
+
+		if err != nil {
+			return
+		}
+		if over {
+			b.shrink()
+		}
+	}
+}
+
+// shrink drops the part of the body every reader has read, waiting for the readers until the body
+// fits in maxSharedBody again.
+func (b *sharedBody) shrink() {
+	b.fullOnce.Do(b.full)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for {
+		off := b.base + len(b.buf)
+		for r := range b.readers {
+			off = min(off, r.off)
+		}
+		b.buf = b.buf[off-b.base:]
+		b.base = off
+		if len(b.buf) <= maxSharedBody {
+			return
+		}
+		b.cond.Wait()
+	}
+}
+
+// reader returns a reader for the whole body, which waits for more of the body to arrive, or false
+// if the start of the body has already been dropped.
+func (b *sharedBody) reader() (io.ReadCloser, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.base > 0 {
+		return nil, false
+	}
+	r := &sharedReader{b: b}
+	b.readers[r] = true
+	return r, true
+}
+
+type sharedReader struct {
+	b   *sharedBody
+	off int
+}
+
+func (r *sharedReader) Read(p []byte) (int, error) {
+	b := r.b
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for r.off == b.base+len(b.buf) && !b.done {
+		b.cond.Wait()
+	}
+	if r.off == b.base+len(b.buf) {
+		if b.err != nil {
+			return 0, b.err
+		}
+		return 0, io.EOF
+	}
+
+	n := copy(p, b.buf[r.off-b.base:])
+	r.off += n
+	b.cond.Broadcast()
+	return n, nil
+}
+
+// Close stops the body being kept for the reader.
+func (r *sharedReader) Close() error {
+	b := r.b
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.readers, r)
+	b.cond.Broadcast()
+	return nil
+}
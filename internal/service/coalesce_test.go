@@ -0,0 +1,191 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"expvar"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestSharedBodyBounded checks that a body bigger than maxSharedBody is passed on whole to a reader
+// that started at the beginning, without being kept whole, and that no reader can join it after the
+// start has been dropped.
+func TestSharedBodyBounded(t *testing.T) {
+	full := false
+	b := newSharedBody(func() { full = true })
+	r, ok := b.reader()
+	if !ok {
+		t.Fatal("reader() failed on a new body")
+	}
+
+	page := bytes.Repeat([]byte("0123456789abcdef"), 3*maxSharedBody/16)
+	go b.fill(io.NopCloser(bytes.NewReader(page)))
+
+	var got bytes.Buffer
+	chunk := make([]byte, copyBufferSize)
+	for {
+		n, err := r.Read(chunk)
+		got.Write(chunk[:n])
+		b.mu.Lock()
+		kept := len(b.buf)
+		b.mu.Unlock()
+		if kept > maxSharedBody+copyBufferSize {
+			t.Fatalf("body keeps %d bytes, want at most %d", kept, maxSharedBody+copyBufferSize)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	r.Close()
+
+	if !bytes.Equal(got.Bytes(), page) {
+		t.Errorf("reader returned %d bytes, want %d", got.Len(), len(page))
+	}
+	if !full {
+		t.Error("full wasn't called")
+	}
+	if _, ok := b.reader(); ok {
+		t.Error("reader() succeeded after the start of the body was dropped")
+	}
+}
+
+func coalescedRequests() int64 {
+	v, _ := proxyMetrics.Get("coalesced_requests").(*expvar.Int)
+	if v == nil {
+		return 0
+	}
+	return v.Value()
+}
+
+func TestFetchSharedCoalesces(t *testing.T) {
+	const want = "<p>Wikipedia</p>"
+	release := make(chan struct{})
+	var fetches atomic.Int32
+	s, request := newFetchTest(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		<-release
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("Set-Cookie", "session=1")
+		io.WriteString(w, want)
+	}))
+
+	const calls = 5
+	before := coalescedRequests()
+	pages := make(chan *page, calls)
+	errs := make(chan error, calls)
+	for i := 0; i < calls; i++ {
+		go func() {
+			p, err := s.fetchShared(context.Background(), request("/wiki/Foo"))
+			if err != nil {
+				errs <- err
+				return
+			}
+			pages <- p
+		}()
+	}
+
+	// Let upstream answer once every other call has joined the first one.
+	deadline := time.Now().Add(5 * time.Second)
+	for coalescedRequests()-before < calls-1 {
+		if time.Now().After(deadline) {
+			t.Fatal("calls didn't join the fetch")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+
+	var got []*page
+	for i := 0; i < calls; i++ {
+		select {
+		case p := <-pages:
+			got = append(got, p)
+		case err := <-errs:
+			t.Fatal(err)
+		}
+	}
+
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("upstream fetched %d times, want 1", n)
+	}
+	cookies := 0
+	for i, p := range got {
+		body, err := io.ReadAll(p.body)
+		p.body.Close()
+		if err != nil || string(body) != want {
+			t.Errorf("page %d body = %q, %v; want %q", i, body, err, want)
+		}
+		if p.header.Get("Set-Cookie") != "" {
+			cookies++
+		}
+		// Each caller rewrites its own headers.
+		p.header.Set("X-Caller", strconv.Itoa(i))
+	}
+	for i, p := range got {
+		if v := p.header.Get("X-Caller"); v != strconv.Itoa(i) {
+			t.Errorf("page %d has the headers of page %s", i, v)
+		}
+	}
+	if cookies != 1 {
+		t.Errorf("%d pages set cookies, want only the one that started the fetch", cookies)
+	}
+}
+
+func TestFetchSharedTimesOut(t *testing.T) {
+	done := make(chan struct{})
+	var fetches atomic.Int32
+	s, request := newFetchTest(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if r.URL.Path == "/stalled-body" {
+			w.Header().Set("Content-Type", "text/html")
+			io.WriteString(w, "<p>start")
+			w.(http.Flusher).Flush()
+		}
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(func() { close(done) })
+	s.inflight.fetchTimeout = 100 * time.Millisecond
+	s.inflight.readTimeout = 100 * time.Millisecond
+
+	// No response.
+	if _, err := s.fetchShared(context.Background(), request("/stalled")); err == nil {
+		t.Error("fetchShared() succeeded without a response from upstream")
+	}
+
+	// No more of the body.
+	p, err := s.fetchShared(context.Background(), request("/stalled-body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(p.body); err == nil {
+		t.Error("reading a stalled body succeeded")
+	}
+	p.body.Close()
+
+	// Neither flight is joined by the requests after them.
+	deadline := time.Now().Add(5 * time.Second)
+	for _, path := range []string{"/stalled", "/stalled-body"} {
+		for {
+			s.inflight.mu.Lock()
+			_, ok := s.inflight.flights[path]
+			s.inflight.mu.Unlock()
+			if !ok {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("flight for %s still in progress", path)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/devhou-se/sreetcode/internal/integration/sreeify"
+)
+
+// Fallback policies for when sreeifying a page fails.
+const (
+	// fallbackOriginal serves the upstream page unmodified.
+	fallbackOriginal = "original"
+	// fallbackLocal sreeifies the page in-process instead.
+	fallbackLocal = "local"
+	// fallbackError fails the request.
+	fallbackError = "error"
+)
+
+func validFallback(f string) bool {
+	return f == fallbackOriginal || f == fallbackLocal || f == fallbackError
+}
+
+// sreeifyPage sreeifies an upstream page, applying the fallback policy if the sreeifier fails. It
+// reports whether the page was sreeified by the configured sreeifier rather than a fallback, and
+// only returns an error if the policy is to fail the request.
+func (s *Server) sreeifyPage(ctx context.Context, page []byte) ([]byte, bool, error) {
+	out, err := s.sreeify.Sreeify(ctx, page)
+	if err == nil {
+		return out, true, nil
+	}
+	return s.fallbackPage(ctx, page, err)
+}
+
+// sreeifyPageStream sreeifies an upstream page with a streaming sreeifier, which writes the page out
+// as it goes. The page is kept until the sreeifier writes its first output, so if it fails before
+// then the fallback policy applies as it does for sreeifyPage; a failure after that aborts the
+// response. It closes page, and returns a *proxyError if the request should fail.
+func (s *Server) sreeifyPageStream(ctx context.Context, ss sreeify.StreamSreeifier, page io.ReadCloser) (io.ReadCloser, bool, error) {
+	in := &recordingReader{r: page}
+	pr, pw := io.Pipe()
+	out := &startWriter{w: pw, in: in, started: make(chan struct{})}
+	failed := make(chan error, 1)
+	go func() {
+		err := ss.SreeifyStream(ctx, out, in)
+		select {
+		case <-out.started:
+			page.Close()
+			pw.CloseWithError(err)
+		default:
+			failed <- err
+		}
+	}()
+
+	var err error
+	select {
+	case <-out.started:
+		return pr, true, nil
+	case err = <-failed:
+	}
+
+	// Nothing was written, so the page is read again from the start.
+	defer page.Close()
+	if in.err != nil {
+		return nil, false, &proxyError{http.StatusInternalServerError, "Error reading response", in.err}
+	}
+	rest, rerr := io.ReadAll(page)
+	if rerr != nil {
+		return nil, false, &proxyError{http.StatusInternalServerError, "Error reading response", rerr}
+	}
+	body := append(in.buf.Bytes(), rest...)
+	if err == nil {
+		// The page sreeified to nothing.
+		return io.NopCloser(bytes.NewReader(nil)), true, nil
+	}
+
+	body, sreeified, err := s.fallbackPage(ctx, body, err)
+	if err != nil {
+		return nil, false, &proxyError{sreeifyErrorStatus(err), "Error sreeifying response", err}
+	}
+	return io.NopCloser(bytes.NewReader(body)), sreeified, nil
+}
+
+// fallbackPage applies the fallback policy to a page the sreeifier failed with err.
+func (s *Server) fallbackPage(ctx context.Context, page []byte, err error) ([]byte, bool, error) {
+	switch s.fallback {
+	case fallbackOriginal:
+		slog.Warn(fmt.Sprintf("Serving original page: %s", err))
+		return page, false, nil
+	case fallbackLocal:
+		slog.Warn(fmt.Sprintf("Sreeifying page locally: %s", err))
+		out, lerr := sreeify.Local{}.Sreeify(ctx, page)
+		if lerr != nil {
+			slog.Error(fmt.Sprintf("Error sreeifying page locally, serving original: %s", lerr))
+			return page, false, nil
+		}
+		return out, false, nil
+	default:
+		return nil, false, err
+	}
+}
+
+// sreeifyErrorStatus returns the HTTP status for a failure to sreeify a page.
+func sreeifyErrorStatus(err error) int {
+	var te *sreeify.TimeoutError
+	switch {
+	case errors.As(err, &te):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, sreeify.ErrCircuitOpen), errors.Is(err, sreeify.ErrStreamClosed):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// recordingReader keeps what is read from r until stop is called.
+type recordingReader struct {
+	r io.Reader
+
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	stopped bool
+	// err is the first error reading r other than io.EOF.
+	err error
+}
+
+func (r *recordingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.stopped {
+		r.buf.Write(p[:n])
+	}
+	if err != nil && err != io.EOF && r.err == nil {
+		r.err = err
+	}
+	return n, err
+}
+
+// stop stops recording and drops what was recorded.
+func (r *recordingReader) stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopped = true
+	r.buf = bytes.Buffer{}
+}
+
+// startWriter closes started when the first bytes are written to it, and stops in recording.
+type startWriter struct {
+	w       io.Writer
+	in      *recordingReader
+	once    sync.Once
+	started chan struct{}
+}
+
+func (w *startWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	w.once.Do(func() {
+		w.in.stop()
+		close(w.started)
+	})
+	return w.w.Write(p)
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// streamSreeifier reads some of its input, writes out, then fails with err.
+type streamSreeifier struct {
+	read int
+	out  string
+	err  error
+}
+
+func (s streamSreeifier) Sreeify(_ context.Context, input []byte) ([]byte, error) {
+	return nil, s.err
+}
+
+func (s streamSreeifier) SreeifyStream(_ context.Context, w io.Writer, r io.Reader) error {
+	if _, err := io.CopyN(io.Discard, r, int64(s.read)); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, s.out); err != nil {
+		return err
+	}
+	return s.err
+}
+
+func TestSreeifyPageStream(t *testing.T) {
+	const page = "<p>Wikipedia, the free encyclopedia</p>"
+	errFailed := errors.New("failed")
+
+	tests := []struct {
+		name      string
+		ss        streamSreeifier
+		fallback  string
+		want      string
+		sreeified bool
+		wantErr   bool
+	}{
+		{"sreeified", streamSreeifier{read: len(page), out: "sreeified"}, fallbackOriginal, "sreeified", true, false},
+		{"empty output", streamSreeifier{read: len(page)}, fallbackOriginal, "", true, false},
+		{"fails before output", streamSreeifier{read: 10, err: errFailed}, fallbackOriginal, page, false, false},
+		{"fails before output locally", streamSreeifier{read: 10, err: errFailed}, fallbackLocal, "<p>Sreekipedia, the Sree encyclopedia</p>", false, false},
+		{"fails before output with error", streamSreeifier{err: errFailed}, fallbackError, "", false, true},
+		{"fails after output", streamSreeifier{read: 10, out: "sree", err: errFailed}, fallbackOriginal, "", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{fallback: tt.fallback}
+			body, sreeified, err := s.sreeifyPageStream(context.Background(), tt.ss, io.NopCloser(strings.NewReader(page)))
+			if err != nil {
+				if !tt.wantErr {
+					t.Fatalf("sreeifyPageStream() error = %v", err)
+				}
+				return
+			}
+			got, err := io.ReadAll(body)
+			body.Close()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("reading body: error = %v, want error %t", err, tt.wantErr)
+			}
+			if err == nil && string(got) != tt.want {
+				t.Errorf("body = %q, want %q", got, tt.want)
+			}
+			if sreeified != tt.sreeified {
+				t.Errorf("sreeified = %t, want %t", sreeified, tt.sreeified)
+			}
+		})
+	}
+}
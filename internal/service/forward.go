@@ -0,0 +1,74 @@
+package service
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// sessionCookieSuffixes identify the MediaWiki cookies that belong to a logged-in user. Requests
+// carrying them get pages specific to that user.
+var sessionCookieSuffixes = []string{"_session", "Session", "UserID", "UserName", "_User", "Token"}
+
+// conditionalHeaders make a request depend on what the client already has.
+var conditionalHeaders = []string{"If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since", "If-Range", "Range"}
+
+// forwardHeaders copies the allowed headers from the client's request r to an upstream request.
+// Referer and Origin are mapped to the upstream host, so upstream sees them as its own.
+func (s *Server) forwardHeaders(dst http.Header, r *http.Request) {
+	for _, name := range s.allowedHeaders {
+		for _, v := range r.Header.Values(name) {
+			dst.Add(name, v)
+		}
+	}
+
+	for _, name := range []string{"Referer", "Origin"} {
+		if v := dst.Get(name); v != "" {
+			dst.Set(name, s.upstreamURL(v))
+		}
+	}
+}
+
+// upstreamURL maps a URL on a sreeki host to the same URL upstream. URLs on other hosts are
+// returned unchanged.
+func (s *Server) upstreamURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
+	}
+	host, m, ok := s.hosts.Upstream(u.Host)
+	if !ok {
+		return raw
+	}
+	u.Scheme, u.Host = m.UpstreamScheme(), host
+	m.UpstreamPath(u)
+	return u.String()
+}
+
+// anonymousRequest reports whether r is an unconditional request from a client that isn't logged
+// in, so gets the same response as any other client making it.
+func anonymousRequest(r *http.Request) bool {
+	if r.Header.Get("Authorization") != "" {
+		return false
+	}
+	for _, name := range conditionalHeaders {
+		if r.Header.Get(name) != "" {
+			return false
+		}
+	}
+	for _, c := range r.Cookies() {
+		if sessionCookie(c.Name) {
+			return false
+		}
+	}
+	return true
+}
+
+func sessionCookie(name string) bool {
+	for _, suffix := range sessionCookieSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/devhou-se/sreetcode/internal/hostmap"
+	"github.com/devhou-se/sreetcode/internal/integration/sreeify"
+)
+
+func TestForwardHeaders(t *testing.T) {
+	s := &Server{
+		hosts:          hostmap.Default(),
+		allowedHeaders: []string{"Accept-Language", "Referer", "Origin", "X-Missing"},
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "http://en.sreekipedia.org/sreeki/Foo", nil)
+	r.Header = http.Header{
+		"Accept-Language": {"en-GB", "en;q=0.8"},
+		"Referer":         {"https://en.sreekipedia.org/sreeki/Bar?action=history"},
+		"Origin":          {"https://de.sreekipedia.org"},
+		"User-Agent":      {"test"},
+		"X-Secret":        {"1"},
+	}
+
+	dst := make(http.Header)
+	s.forwardHeaders(dst, r)
+	want := http.Header{
+		"Accept-Language": {"en-GB", "en;q=0.8"},
+		"Referer":         {"https://en.wikipedia.org/wiki/Bar?action=history"},
+		"Origin":          {"https://de.wikipedia.org"},
+	}
+	if !reflect.DeepEqual(dst, want) {
+		t.Errorf("forwardHeaders() = %v, want %v", dst, want)
+	}
+}
+
+func TestUpstreamURL(t *testing.T) {
+	s := &Server{hosts: hostmap.Default()}
+	tests := []struct {
+		in, want string
+	}{
+		{"https://en.sreekipedia.org/sreeki/Foo", "https://en.wikipedia.org/wiki/Foo"},
+		{"http://en.sreekipedia.org/w/index.php?title=Foo", "https://en.wikipedia.org/w/index.php?title=Foo"},
+		{"https://de.sreekipedia.org", "https://de.wikipedia.org"},
+		// URLs on other hosts, and relative ones, aren't the proxy's to map.
+		{"https://example.com/sreeki/Foo", "https://example.com/sreeki/Foo"},
+		{"/sreeki/Foo", "/sreeki/Foo"},
+	}
+	for _, tt := range tests {
+		if got := s.upstreamURL(tt.in); got != tt.want {
+			t.Errorf("upstreamURL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestAnonymousRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   bool
+	}{
+		{"no credentials", http.Header{}, true},
+		{"tracking cookies", http.Header{"Cookie": {"GeoIP=GB; WMF-Last-Access=16-Oct-2026"}}, true},
+		{"session", http.Header{"Cookie": {"GeoIP=GB; enwikiSession=abc"}}, false},
+		{"central session", http.Header{"Cookie": {"centralauth_session=abc"}}, false},
+		{"user name", http.Header{"Cookie": {"enwikiUserName=Foo"}}, false},
+		{"user id", http.Header{"Cookie": {"enwikiUserID=1"}}, false},
+		{"central user", http.Header{"Cookie": {"centralauth_User=Foo"}}, false},
+		{"token", http.Header{"Cookie": {"centralauth_Token=abc"}}, false},
+		// The suffix has to end the name.
+		{"session-like name", http.Header{"Cookie": {"enwikiSessionInfo=1"}}, true},
+		{"authorization", http.Header{"Authorization": {"Bearer abc"}}, false},
+		{"conditional", http.Header{"If-None-Match": {`"1"`}}, false},
+		{"range", http.Header{"Range": {"bytes=0-9"}}, false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header = tt.header
+		if got := anonymousRequest(r); got != tt.want {
+			t.Errorf("%s: anonymousRequest() = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestProxyForwardsRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		url      string
+		header   http.Header
+		upstream string
+		want     http.Header
+	}{
+		{
+			name:     "query string",
+			method:   http.MethodGet,
+			url:      "http://en.sreekipedia.org/w/index.php?title=Foo&action=history&offset=",
+			upstream: "https://en.wikipedia.org/w/index.php?title=Foo&action=history&offset=",
+			header:   http.Header{"Referer": {"http://en.sreekipedia.org/sreeki/Bar"}},
+			want:     http.Header{"Referer": {"https://en.wikipedia.org/wiki/Bar"}},
+		},
+		{
+			// Cached pages are shared with every client, so none of their cookies go upstream.
+			name:     "cookies on a cached page",
+			method:   http.MethodGet,
+			url:      "http://en.sreekipedia.org/sreeki/Foo",
+			upstream: "https://en.wikipedia.org/wiki/Foo",
+			header:   http.Header{"Cookie": {"GeoIP=GB"}},
+			want:     http.Header{},
+		},
+		{
+			name:     "session cookies",
+			method:   http.MethodGet,
+			url:      "http://en.sreekipedia.org/sreeki/Foo",
+			upstream: "https://en.wikipedia.org/wiki/Foo",
+			header:   http.Header{"Cookie": {"GeoIP=GB; enwikiSession=abc"}},
+			want:     http.Header{"Cookie": {"GeoIP=GB; enwikiSession=abc"}},
+		},
+		{
+			name:     "post",
+			method:   http.MethodPost,
+			url:      "http://en.sreekipedia.org/w/index.php?action=submit",
+			upstream: "https://en.wikipedia.org/w/index.php?action=submit",
+			header:   http.Header{"Cookie": {"GeoIP=GB"}, "Origin": {"http://en.sreekipedia.org"}},
+			want:     http.Header{"Cookie": {"GeoIP=GB"}, "Origin": {"https://en.wikipedia.org"}},
+		},
+	}
+
+	for _, tt := range tests {
+		s, _ := newRewriteTest(t, "")
+		up := &fakeUpstream{page: "<p>page</p>"}
+		s.transport = up
+		s.sreeify = sreeify.Noop{}
+		s.fallback = fallbackOriginal
+		s.inflight = newFlightGroup()
+		s.allowedHeaders = []string{"Cookie", "Referer", "Origin"}
+		r := chi.NewRouter()
+		s.proxyRoutes(r)
+
+		req := httptest.NewRequest(tt.method, tt.url, strings.NewReader(""))
+		req.Header = tt.header
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		if len(up.urls) != 1 || up.urls[0] != tt.upstream {
+			t.Errorf("%s: fetched %q, want %q", tt.name, up.urls, tt.upstream)
+			continue
+		}
+		got := make(http.Header)
+		for _, name := range s.allowedHeaders {
+			if v := up.headers[0].Values(name); len(v) > 0 {
+				got[name] = v
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: forwarded %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// hopHeaders are the hop-by-hop headers, which apply to a single connection and mustn't be
+// forwarded by a proxy.
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// upstreamEncodings are the content encodings requested from upstream. Pages are decoded before
+// they are sreeified, so these must all be supported by decodeBody.
+const upstreamEncodings = "br, gzip"
+
+// removeHopHeaders removes the hop-by-hop headers from h, including any named by its Connection
+// header.
+func removeHopHeaders(h http.Header) {
+	for _, v := range h.Values("Connection") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				h.Del(name)
+			}
+		}
+	}
+	for _, name := range hopHeaders {
+		h.Del(name)
+	}
+}
+
+// setForwardedHeaders tells upstream who the proxied request r came from.
+func setForwardedHeaders(h http.Header, r *http.Request) {
+	if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		if prior := r.Header.Values("X-Forwarded-For"); len(prior) > 0 {
+			ip = strings.Join(prior, ", ") + ", " + ip
+		}
+		h.Set("X-Forwarded-For", ip)
+	}
+
+	h.Set("X-Forwarded-Host", r.Host)
+
+	h.Set("X-Forwarded-Proto", requestScheme(r))
+}
+
+// decodeBody returns a reader for the decoded body of a response with the given Content-Encoding.
+func decodeBody(body io.ReadCloser, encoding string) (io.ReadCloser, error) {
+	switch strings.ToLower(encoding) {
+	case "", "identity":
+		return body, nil
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		return readCloser{zr, body}, nil
+	case "br":
+		return readCloser{brotli.NewReader(body), body}, nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding: %s", encoding)
+	}
+}
+
+// readCloser reads from a decoder, and closes the body underneath it.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// compressor is a writer that compresses what's written to it.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// newCompressor returns a compressor for the given Content-Encoding, which must be one returned by
+// preferredEncoding.
+func newCompressor(w io.Writer, encoding string) compressor {
+	if encoding == "br" {
+		return brotli.NewWriter(w)
+	}
+	return gzip.NewWriter(w)
+}
+
+// acceptsEncoding reports whether the client accepts responses with the given Content-Encoding.
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	encoding = strings.ToLower(encoding)
+	if encoding == "" || encoding == "identity" {
+		return true
+	}
+
+	// An encoding named explicitly takes precedence over the wildcard.
+	accepted, wildcard := -1.0, -1.0
+	for _, v := range r.Header.Values("Accept-Encoding") {
+		for _, part := range strings.Split(v, ",") {
+			name, params, _ := strings.Cut(part, ";")
+			q := 1.0
+			if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+			switch strings.ToLower(strings.TrimSpace(name)) {
+			case encoding:
+				accepted = q
+			case "*":
+				wildcard = q
+			}
+		}
+	}
+	if accepted < 0 {
+		accepted = wildcard
+	}
+	return accepted > 0
+}
+
+// preferredEncoding returns the encoding to compress rewritten pages with for the client, or "" to
+// leave them uncompressed.
+func preferredEncoding(r *http.Request) string {
+	for _, encoding := range []string{"br", "gzip"} {
+		if acceptsEncoding(r, encoding) {
+			return encoding
+		}
+	}
+	return ""
+}
+
+// addVary adds a header to the response's Vary header unless it's already listed.
+func addVary(h http.Header, name string) {
+	for _, v := range h.Values("Vary") {
+		for _, existing := range strings.Split(v, ",") {
+			if e := strings.TrimSpace(existing); e == "*" || strings.EqualFold(e, name) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", name)
+}
+
+// hasBody reports whether a response to a request with method can have a body with status.
+func hasBody(method string, status int) bool {
+	switch {
+	case method == http.MethodHead:
+		return false
+	case status == http.StatusNoContent, status == http.StatusNotModified:
+		return false
+	case status >= 100 && status < 200:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRemoveHopHeaders(t *testing.T) {
+	h := http.Header{
+		"Connection":        {"keep-alive, X-Private", "x-other"},
+		"Keep-Alive":        {"timeout=5"},
+		"Transfer-Encoding": {"chunked"},
+		"Upgrade":           {"websocket"},
+		"X-Private":         {"1"},
+		"X-Other":           {"2"},
+		"Content-Type":      {"text/html"},
+		"Cache-Control":     {"max-age=60"},
+	}
+	removeHopHeaders(h)
+
+	want := http.Header{
+		"Content-Type":  {"text/html"},
+		"Cache-Control": {"max-age=60"},
+	}
+	if !reflect.DeepEqual(h, want) {
+		t.Errorf("removeHopHeaders() left %v, want %v", h, want)
+	}
+}
+
+func TestSetForwardedHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   http.Header
+	}{
+		{
+			name:   "first proxy",
+			header: http.Header{},
+			want: http.Header{
+				"X-Forwarded-For":   {"10.0.0.2"},
+				"X-Forwarded-Host":  {"en.sreekipedia.org"},
+				"X-Forwarded-Proto": {"http"},
+			},
+		},
+		{
+			name:   "behind another proxy",
+			header: http.Header{"X-Forwarded-For": {"1.1.1.1, 2.2.2.2", "3.3.3.3"}, "X-Forwarded-Proto": {"https"}},
+			want: http.Header{
+				"X-Forwarded-For":   {"1.1.1.1, 2.2.2.2, 3.3.3.3, 10.0.0.2"},
+				"X-Forwarded-Host":  {"en.sreekipedia.org"},
+				"X-Forwarded-Proto": {"https"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "http://en.sreekipedia.org/sreeki/Foo", nil)
+		r.RemoteAddr = "10.0.0.2:1234"
+		r.Header = tt.header
+
+		h := make(http.Header)
+		setForwardedHeaders(h, r)
+		if !reflect.DeepEqual(h, tt.want) {
+			t.Errorf("%s: setForwardedHeaders() = %v, want %v", tt.name, h, tt.want)
+		}
+	}
+}
+
+func TestDecodeBodyRoundTrip(t *testing.T) {
+	const text = "<p>Wikipedia, the free encyclopedia</p>"
+
+	for _, enc := range []string{"gzip", "br"} {
+		var buf bytes.Buffer
+		c := newCompressor(&buf, enc)
+		if _, err := io.WriteString(c, text); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := decodeBody(io.NopCloser(&buf), strings.ToUpper(enc))
+		if err != nil {
+			t.Fatalf("%s: decodeBody() error = %v", enc, err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("%s: reading decoded body: %v", enc, err)
+		}
+		if string(got) != text {
+			t.Errorf("%s: decoded %q, want %q", enc, got, text)
+		}
+	}
+
+	if _, err := decodeBody(io.NopCloser(strings.NewReader(text)), "compress"); err == nil {
+		t.Error("decodeBody() with an unsupported encoding succeeded")
+	}
+	r, err := decodeBody(io.NopCloser(strings.NewReader(text)), "identity")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := io.ReadAll(r); string(got) != text {
+		t.Errorf("identity: decoded %q, want %q", got, text)
+	}
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	tests := []struct {
+		accept    string
+		encoding  string
+		want      bool
+		preferred string
+	}{
+		{"", "gzip", false, ""},
+		{"", "identity", true, ""},
+		{"gzip, deflate", "gzip", true, "gzip"},
+		{"gzip, deflate", "br", false, "gzip"},
+		// The proxy's own preference decides between accepted encodings, not the client's order.
+		{"gzip, br", "br", true, "br"},
+		{"gzip;q=1.0, br;q=0.5", "br", true, "br"},
+		{"GZIP;Q=1", "gzip", true, "gzip"},
+		{"br;q=0, gzip", "br", false, "gzip"},
+		{"gzip;q=0", "gzip", false, ""},
+		{"*", "br", true, "br"},
+		{"*;q=0", "gzip", false, ""},
+		// An encoding named explicitly overrides the wildcard.
+		{"*;q=0, gzip", "gzip", true, "gzip"},
+		{"br;q=0, *", "br", false, "gzip"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept-Encoding", tt.accept)
+		}
+		if got := acceptsEncoding(r, tt.encoding); got != tt.want {
+			t.Errorf("acceptsEncoding(%q, %q) = %t, want %t", tt.accept, tt.encoding, got, tt.want)
+		}
+		if got := preferredEncoding(r); got != tt.preferred {
+			t.Errorf("preferredEncoding(%q) = %q, want %q", tt.accept, got, tt.preferred)
+		}
+	}
+}
+
+func TestAddVary(t *testing.T) {
+	tests := []struct {
+		vary []string
+		want []string
+	}{
+		{nil, []string{"Accept-Encoding"}},
+		{[]string{"Cookie"}, []string{"Cookie", "Accept-Encoding"}},
+		{[]string{"Cookie, accept-encoding"}, []string{"Cookie, accept-encoding"}},
+		{[]string{"Cookie", "Accept-Encoding"}, []string{"Cookie", "Accept-Encoding"}},
+		{[]string{"*"}, []string{"*"}},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		for _, v := range tt.vary {
+			h.Add("Vary", v)
+		}
+		addVary(h, "Accept-Encoding")
+		if got := h.Values("Vary"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("addVary(%q) = %q, want %q", tt.vary, got, tt.want)
+		}
+	}
+}
+
+func TestHasBody(t *testing.T) {
+	tests := []struct {
+		method string
+		status int
+		want   bool
+	}{
+		{http.MethodGet, http.StatusOK, true},
+		{http.MethodGet, http.StatusNotFound, true},
+		{http.MethodPost, http.StatusCreated, true},
+		{http.MethodHead, http.StatusOK, false},
+		{http.MethodGet, http.StatusNoContent, false},
+		{http.MethodGet, http.StatusNotModified, false},
+		{http.MethodGet, http.StatusSwitchingProtocols, false},
+	}
+	for _, tt := range tests {
+		if got := hasBody(tt.method, tt.status); got != tt.want {
+			t.Errorf("hasBody(%s, %d) = %t, want %t", tt.method, tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestPageWriteEncoding(t *testing.T) {
+	const text = "<p>Sreekipedia, the Sree encyclopedia</p>"
+
+	var gzipped bytes.Buffer
+	c := newCompressor(&gzipped, "gzip")
+	io.WriteString(c, text)
+	c.Close()
+
+	tests := []struct {
+		name     string
+		accept   string
+		page     *page
+		encoding string
+		// dropsLength is true if the body is re-encoded, so upstream's Content-Length is wrong.
+		dropsLength bool
+	}{
+		{
+			name:        "rewritten page compressed for the client",
+			accept:      "br",
+			page:        &page{header: http.Header{"Content-Length": {"41"}}, body: io.NopCloser(strings.NewReader(text)), compress: true},
+			encoding:    "br",
+			dropsLength: true,
+		},
+		{
+			name: "rewritten page for a client that takes no encoding",
+			page: &page{header: http.Header{"Content-Length": {"41"}}, body: io.NopCloser(strings.NewReader(text)), compress: true},
+		},
+		{
+			name:   "upstream encoding the client doesn't accept",
+			accept: "br",
+			page: &page{
+				header: http.Header{"Content-Encoding": {"gzip"}, "Content-Length": {"99"}},
+				body:   io.NopCloser(bytes.NewReader(gzipped.Bytes())),
+			},
+			dropsLength: true,
+		},
+	}
+	for _, tt := range tests {
+		tt.page.status = http.StatusOK
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept-Encoding", tt.accept)
+		}
+		w := httptest.NewRecorder()
+		tt.page.write(w, r)
+
+		res := w.Result()
+		if got := res.Header.Get("Content-Encoding"); got != tt.encoding {
+			t.Errorf("%s: Content-Encoding = %q, want %q", tt.name, got, tt.encoding)
+		}
+		if got := res.Header.Get("Content-Length"); (got == "") != tt.dropsLength {
+			t.Errorf("%s: Content-Length = %q, want it dropped: %t", tt.name, got, tt.dropsLength)
+		}
+
+		body, err := decodeBody(res.Body, tt.encoding)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := io.ReadAll(body); string(got) != text {
+			t.Errorf("%s: body = %q, want %q", tt.name, got, text)
+		}
+	}
+}
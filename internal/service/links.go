@@ -0,0 +1,133 @@
+package service
+
+import (
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// linkAttributes are the attributes holding the URLs rewritten by rewriteLinks, with how to find
+// the URLs in their values.
+var linkAttributes = map[string]func(value string, rewrite func(string) string) string{
+	"href":     rewriteURL,
+	"src":      rewriteURL,
+	"action":   rewriteURL,
+	"poster":   rewriteURL,
+	"data-src": rewriteURL,
+	"srcset":   rewriteSrcset,
+	"style":    rewriteCSSURLs,
+}
+
+// rewriteLinks copies an HTML document from r to w, passing each URL in a link attribute through
+// rewrite. Tags without a rewritten link are copied byte-for-byte.
+func rewriteLinks(w io.Writer, r io.Reader, rewrite func(string) string) error {
+	z := html.NewTokenizer(r)
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if z.Err() == io.EOF {
+				// An unterminated tag at the end of the document is left in Raw.
+				_, err := w.Write(z.Raw())
+				return err
+			}
+			return z.Err()
+		}
+
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			if _, err := w.Write(z.Raw()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Raw must be copied before Token is called, as Token lowercases the buffer in place.
+		raw := append([]byte(nil), z.Raw()...)
+		tok := z.Token()
+
+		changed := false
+		for i, a := range tok.Attr {
+			rewriteValue := linkAttributes[a.Key]
+			if a.Namespace != "" || rewriteValue == nil {
+				continue
+			}
+			if v := rewriteValue(a.Val, rewrite); v != a.Val {
+				tok.Attr[i].Val = v
+				changed = true
+			}
+		}
+
+		if changed {
+			_, err := io.WriteString(w, tok.String())
+			if err != nil {
+				return err
+			}
+		} else if _, err := w.Write(raw); err != nil {
+			return err
+		}
+	}
+}
+
+// rewriteURL rewrites an attribute holding a single URL, which may be surrounded by whitespace.
+func rewriteURL(value string, rewrite func(string) string) string {
+	link := strings.TrimSpace(value)
+	if link == "" {
+		return value
+	}
+	if v := rewrite(link); v != link {
+		return v
+	}
+	return value
+}
+
+// rewriteSrcset rewrites the URL of each image candidate in a srcset attribute, such as
+// "a.png 1x, b.png 2x", keeping the descriptors and separators as they are.
+func rewriteSrcset(value string, rewrite func(string) string) string {
+	var b strings.Builder
+	rest := value
+	for rest != "" {
+		// Each candidate is a URL, which may contain commas but not whitespace, followed by
+		// descriptors up to the next comma.
+		start := strings.IndexFunc(rest, func(r rune) bool { return r != ',' && !isHTMLSpace(r) })
+		if start < 0 {
+			break
+		}
+		b.WriteString(rest[:start])
+		rest = rest[start:]
+
+		end := strings.IndexFunc(rest, isHTMLSpace)
+		if end < 0 {
+			end = len(rest)
+		}
+		link := strings.TrimRight(rest[:end], ",")
+		b.WriteString(rewrite(link))
+		rest = rest[len(link):]
+
+		if end == len(link) {
+			descriptors := strings.IndexByte(rest, ',')
+			if descriptors < 0 {
+				descriptors = len(rest)
+			}
+			b.WriteString(rest[:descriptors])
+			rest = rest[descriptors:]
+		}
+	}
+	b.WriteString(rest)
+	return b.String()
+}
+
+func isHTMLSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\f' || r == '\r'
+}
+
+// pipe runs transform over r in the background, returning a reader for its output. Closing the
+// reader stops the transform.
+func pipe(r io.ReadCloser, transform func(w io.Writer, r io.Reader) error) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		err := transform(pw, r)
+		r.Close()
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRewriteLinks(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefix   string
+		in, want string
+	}{
+		{
+			name: "same site",
+			in:   `<a href="/wiki/Foo" title="Wiki">Wiki</a><a href="https://en.wikipedia.org/wiki/Bar">`,
+			want: `<a href="/sreeki/Foo" title="Wiki">Wiki</a><a href="/sreeki/Bar">`,
+		},
+		{
+			name: "protocol-relative",
+			in:   `<a href="//en.wikipedia.org/wiki/Foo"><a href="//de.wikipedia.org/wiki/Foo">`,
+			want: `<a href="/sreeki/Foo"><a href="//de.sreekipedia.org/sreeki/Foo">`,
+		},
+		{
+			name: "mounted site",
+			in:   `<a href="https://en.wiktionary.org/wiki/Foo">`,
+			want: `<a href="/dict/sreeki/Foo">`,
+		},
+		{
+			name: "unmapped sites",
+			in:   `<a href="https://example.com/wiki/Foo">x</a><img src="//upload.wikimedia.org/a.png"><a href="mailto:a@wikipedia.org">`,
+			want: `<a href="https://example.com/wiki/Foo">x</a><img src="//upload.wikimedia.org/a.png"><a href="mailto:a@wikipedia.org">`,
+		},
+		{
+			name: "fragment",
+			in:   `<a href="#cite">`,
+			want: `<a href="#cite">`,
+		},
+		{
+			name: "every link attribute",
+			in:   `<video poster="/wiki/P.png" src="//en.wikipedia.org/wiki/V.webm"><img data-src="/wiki/L.png"><div style="background: url(/wiki/B.png)">`,
+			want: `<video poster="/sreeki/P.png" src="/sreeki/V.webm"><img data-src="/sreeki/L.png"><div style="background: url(/sreeki/B.png)">`,
+		},
+		{
+			name: "srcset",
+			in:   `<img srcset="https://en.wikipedia.org/wiki/A,1.png 1.5x, /wiki/B.png">`,
+			want: `<img srcset="/sreeki/A,1.png 1.5x, /sreeki/B.png">`,
+		},
+		{
+			name:   "mount",
+			prefix: "/dict/",
+			in:     `<a href="/wiki/Foo"><form action="/w/index.php"><div style="background: url(/wiki/B.png)">`,
+			want:   `<a href="/dict/sreeki/Foo"><form action="/dict/w/index.php"><div style="background: url(/dict/sreeki/B.png)">`,
+		},
+		{
+			name:   "srcset on a mount",
+			prefix: "/dict/",
+			in:     `<img srcset="/w/a.png 320w,/w/b.png 640w" sizes="50vw">`,
+			want:   `<img srcset="/dict/w/a.png 320w,/dict/w/b.png 640w" sizes="50vw">`,
+		},
+		{
+			name:   "other sites from a mount",
+			prefix: "/dict/",
+			in:     `<a href="//en.wikipedia.org/wiki/Foo"><a href="https://en.wiktionary.org/wiki/Foo">`,
+			want:   `<a href="//en.sreekipedia.org/sreeki/Foo"><a href="/dict/sreeki/Foo">`,
+		},
+		{
+			// Tags that aren't rewritten keep their case and quoting.
+			name: "unchanged tags",
+			in:   `<IMG SRC='//upload.wikimedia.org/a.png' ALT=Wiki>`,
+			want: `<IMG SRC='//upload.wikimedia.org/a.png' ALT=Wiki>`,
+		},
+	}
+
+	for _, tt := range tests {
+		s, rt := newRewriteTest(t, tt.prefix)
+		var b bytes.Buffer
+		err := rewriteLinks(&b, strings.NewReader(tt.in), func(link string) string {
+			return s.linkURL(link, rt)
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := b.String(); got != tt.want {
+			t.Errorf("%s: rewriteLinks() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRewriteSrcset(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"a.png", "<a.png>"},
+		{"a.png 1x, b.png 2x", "<a.png> 1x, <b.png> 2x"},
+		{"a.png 320w,b.png 640w", "<a.png> 320w,<b.png> 640w"},
+		{"  a.png\t1.5x ,\n b.png  ", "  <a.png>\t1.5x ,\n <b.png>  "},
+		// URLs may contain commas, but a comma ending one separates it from the next candidate.
+		{"a,1.png 1x, b.png,c.png 2x", "<a,1.png> 1x, <b.png,c.png> 2x"},
+		{"a.png, b.png", "<a.png>, <b.png>"},
+		{"//host/a.png 100w", "<//host/a.png> 100w"},
+		{"", ""},
+		{" , ", " , "},
+	}
+	mark := func(link string) string { return "<" + link + ">" }
+	for _, tt := range tests {
+		if got := rewriteSrcset(tt.in, mark); got != tt.want {
+			t.Errorf("rewriteSrcset(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/devhou-se/sreetcode/internal/hostmap"
+	"github.com/devhou-se/sreetcode/internal/util"
+)
+
+// route is where a request is proxied to.
+type route struct {
+	// host is the upstream host.
+	host    string
+	mapping *hostmap.Mapping
+	// prefix is the path prefix a sister site is mounted under, such as "/dict/". It is empty for
+	// requests to a site's own host.
+	prefix string
+	// sreekiHost is the sreeki host of the site, used to recognise links to a mounted site.
+	sreekiHost string
+}
+
+// loadMounts creates routes for the sister sites in util.URLMappings, which are served under a
+// path prefix of every host. Sites can be given by either their sreeki or their upstream host.
+func loadMounts(hosts *hostmap.Table) ([]*route, error) {
+	var mounts []*route
+	for site, prefix := range util.URLMappings {
+		u, err := url.Parse(site)
+		if err != nil {
+			return nil, fmt.Errorf("parsing mounted site %s: %w", site, err)
+		}
+
+		rt := &route{prefix: prefix}
+		if host, m, ok := hosts.Upstream(u.Host); ok {
+			rt.host, rt.mapping, rt.sreekiHost = host, m, u.Hostname()
+		} else if sreeki, m, ok := hosts.Sreeki(u.Host); ok {
+			rt.host, rt.mapping, rt.sreekiHost = u.Hostname(), m, sreeki
+		} else {
+			return nil, fmt.Errorf("no host mapping for mounted site %s", site)
+		}
+
+		mounts = append(mounts, rt)
+	}
+	return mounts, nil
+}
+
+// mountHandler proxies requests under a mount's prefix to its site.
+func (s *Server) mountHandler(rt *route) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.proxy(w, r, rt)
+	}
+}
+
+// mountURL rewrites a link on a page proxied through rt to stay within the mounts. Links to
+// mounted sites are moved under their prefix, and so are root-relative links on a page of a
+// mounted site. Other links are returned unchanged.
+func (s *Server) mountURL(raw string, rt *route) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+
+	target := rt
+	if u.Host != "" {
+		target = s.mountFor(u.Hostname())
+		if target == nil {
+			return raw
+		}
+		u.Scheme, u.Host = "", ""
+	} else if target.prefix == "" || !strings.HasPrefix(u.Path, "/") {
+		return raw
+	}
+
+	target.mapping.SreekiPath(u)
+	hostmap.ReplacePathPrefix(u, "/", target.prefix)
+	return u.String()
+}
+
+// mountFor returns the mount of the site on host, which may be its sreeki or its upstream host.
+func (s *Server) mountFor(host string) *route {
+	host = strings.ToLower(host)
+	for _, rt := range s.mounts {
+		if host == rt.host || host == rt.sreekiHost {
+			return rt
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/devhou-se/sreetcode/internal/integration/sreeify"
+)
+
+// fakeUpstream answers every request with the same HTML page, recording the URLs and headers
+// requested.
+type fakeUpstream struct {
+	page string
+
+	mu      sync.Mutex
+	urls    []string
+	headers []http.Header
+}
+
+func (f *fakeUpstream) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.mu.Lock()
+	f.urls = append(f.urls, req.URL.String())
+	f.headers = append(f.headers, req.Header.Clone())
+	f.mu.Unlock()
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"text/html; charset=UTF-8"}},
+		Body:       io.NopCloser(strings.NewReader(f.page)),
+		Request:    req,
+	}, nil
+}
+
+func TestMountRouting(t *testing.T) {
+	const page = `<a href="/wiki/Bar">Bar</a> <a href="https://en.wiktionary.org/wiki/Baz">Baz</a> ` +
+		`<a href="https://en.wikipedia.org/wiki/Qux">Qux</a>`
+
+	tests := []struct {
+		name     string
+		url      string
+		upstream string
+		body     string
+	}{
+		{
+			name:     "site",
+			url:      "http://en.sreekipedia.org/sreeki/Foo?action=view",
+			upstream: "https://en.wikipedia.org/wiki/Foo?action=view",
+			body:     `<a href="/sreeki/Bar">Bar</a> <a href="/dict/sreeki/Baz">Baz</a> <a href="/sreeki/Qux">Qux</a>`,
+		},
+		{
+			name:     "mount",
+			url:      "http://en.sreekipedia.org/dict/sreeki/Foo",
+			upstream: "https://en.wiktionary.org/wiki/Foo",
+			body:     `<a href="/dict/sreeki/Bar">Bar</a> <a href="/dict/sreeki/Baz">Baz</a> <a href="https://en.sreekipedia.org/sreeki/Qux">Qux</a>`,
+		},
+		{
+			name:     "mount on another host",
+			url:      "http://localhost:8080/quote/sreeki/Foo",
+			upstream: "https://en.wikiquote.org/wiki/Foo",
+			body:     `<a href="/quote/sreeki/Bar">Bar</a> <a href="/dict/sreeki/Baz">Baz</a> <a href="https://en.sreekipedia.org/sreeki/Qux">Qux</a>`,
+		},
+	}
+
+	for _, tt := range tests {
+		s, _ := newRewriteTest(t, "")
+		up := &fakeUpstream{page: page}
+		s.transport = up
+		s.sreeify = sreeify.Noop{}
+		s.fallback = fallbackOriginal
+		s.inflight = newFlightGroup()
+		r := chi.NewRouter()
+		s.proxyRoutes(r)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, http.StatusOK)
+		}
+		if len(up.urls) != 1 || up.urls[0] != tt.upstream {
+			t.Errorf("%s: fetched %q, want %q", tt.name, up.urls, tt.upstream)
+		}
+		if got := w.Body.String(); got != tt.body {
+			t.Errorf("%s: body = %q, want %q", tt.name, got, tt.body)
+		}
+	}
+}
+
+func TestMountRedirectsUpstreamPaths(t *testing.T) {
+	s, _ := newRewriteTest(t, "")
+	up := &fakeUpstream{}
+	s.transport = up
+	r := chi.NewRouter()
+	s.proxyRoutes(r)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://en.sreekipedia.org/dict/wiki/Foo?x=1", nil))
+
+	if w.Code != http.StatusTemporaryRedirect || w.Header().Get("Location") != "/dict/sreeki/Foo?x=1" {
+		t.Errorf("got %d to %q, want a redirect to /dict/sreeki/Foo?x=1", w.Code, w.Header().Get("Location"))
+	}
+	if len(up.urls) != 0 {
+		t.Errorf("fetched %q, want nothing fetched", up.urls)
+	}
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/devhou-se/sreetcode/internal/hostmap"
+)
+
+// urlHeaders are the response headers whose whole value is a URL.
+var urlHeaders = []string{"Location", "Content-Location"}
+
+// linkTarget matches the URL of each link in a Link header.
+var linkTarget = regexp.MustCompile(`<([^>]*)>`)
+
+// refreshURL matches the URL in a Refresh header, such as "5; url=https://en.wikipedia.org/".
+var refreshURL = regexp.MustCompile(`(?i)^(\s*\d+\s*[;,]\s*url\s*=\s*['"]?)([^'"]*)(['"]?\s*)$`)
+
+// rewriteResponseHeaders maps the upstream URLs and cookie domains in a response to a client's
+// request r, proxied through rt, back to the sreeki hosts, so following them keeps the client on
+// the proxy.
+func (s *Server) rewriteResponseHeaders(h http.Header, r *http.Request, rt *route) {
+	for _, name := range urlHeaders {
+		if v := h.Get(name); v != "" {
+			h.Set(name, s.sreekiURL(v, r, rt))
+		}
+	}
+
+	if links := h.Values("Link"); len(links) > 0 {
+		h.Del("Link")
+		for _, v := range links {
+			h.Add("Link", linkTarget.ReplaceAllStringFunc(v, func(m string) string {
+				return "<" + s.sreekiURL(m[1:len(m)-1], r, rt) + ">"
+			}))
+		}
+	}
+
+	if v := h.Get("Refresh"); v != "" {
+		if m := refreshURL.FindStringSubmatch(v); m != nil {
+			h.Set("Refresh", m[1]+s.sreekiURL(m[2], r, rt)+m[3])
+		}
+	}
+
+	s.rewriteSetCookies(h, r.Host, rt)
+}
+
+// sreekiURL maps an upstream URL back to the sreeki site, the reverse of upstreamURL. URLs on the
+// site r was proxied to go back to the host r was made to, so a client on localhost stays there,
+// and URLs on mounted sites go under their prefix. URLs on other hosts that can't be mapped are
+// returned unchanged.
+func (s *Server) sreekiURL(raw string, r *http.Request, rt *route) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+
+	if u.Host != "" && !strings.EqualFold(u.Hostname(), rt.host) {
+		return s.otherSiteURL(u, raw, rt)
+	}
+
+	// Relative URLs are on the site r was proxied to.
+	if u.Host != "" {
+		u.Host = r.Host
+		if u.Scheme != "" {
+			u.Scheme = requestScheme(r)
+		}
+	}
+	if strings.HasPrefix(u.Path, "/") {
+		rt.mapping.SreekiPath(u)
+		if rt.prefix != "" {
+			hostmap.ReplacePathPrefix(u, "/", rt.prefix)
+		}
+	}
+	return u.String()
+}
+
+// linkURL maps a link in a page proxied through rt to the sreeki site, so following it keeps the
+// client on the proxy. Pages are shared between the clients of every host that proxies a site, so
+// absolute links to the page's own site are made root-relative rather than pointing at one of them.
+// Links that can't be mapped, and relative links that don't need to be, are returned unchanged.
+func (s *Server) linkURL(raw string, rt *route) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Opaque != "" || u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" {
+		return raw
+	}
+
+	if u.Host != "" && !strings.EqualFold(u.Hostname(), rt.host) {
+		return s.otherSiteURL(u, raw, rt)
+	}
+
+	changed := false
+	if u.Host != "" {
+		u.Scheme, u.Host, u.User = "", "", nil
+		if u.Path == "" {
+			u.Path = "/"
+		}
+		changed = true
+	}
+	if strings.HasPrefix(u.Path, "/") {
+		changed = rt.mapping.SreekiPath(u) || changed
+		if rt.prefix != "" {
+			hostmap.ReplacePathPrefix(u, "/", rt.prefix)
+			changed = true
+		}
+	}
+
+	if !changed {
+		return raw
+	}
+	return u.String()
+}
+
+// otherSiteURL maps u, the parsed form of raw, from an upstream site other than the one rt proxies
+// to its mount or its own sreeki host. URLs on hosts that can't be mapped are returned unchanged.
+func (s *Server) otherSiteURL(u *url.URL, raw string, rt *route) string {
+	if s.mountFor(u.Hostname()) != nil {
+		return s.mountURL(raw, rt)
+	}
+	h, m, ok := s.hosts.Sreeki(u.Host)
+	if !ok {
+		return raw
+	}
+	u.Host = h
+	m.SreekiPath(u)
+	return u.String()
+}
+
+// requestScheme returns the scheme the client used to make r.
+func requestScheme(r *http.Request) string {
+	// Behind a load balancer, the protocol the client used is only known from its headers.
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		return proto
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// rewriteSetCookies moves the cookies upstream sets for its own domain onto the sreeki domain the
+// client requested host on. Cookies for a sreeki domain that doesn't cover host, such as on
+// localhost, are made host-only instead, and cookies for other domains are dropped. Cookie paths
+// are mapped like links on the site rt proxies.
+func (s *Server) rewriteSetCookies(h http.Header, host string, rt *route) {
+	if hh, _, err := net.SplitHostPort(host); err == nil {
+		host = hh
+	}
+
+	cookies := h.Values("Set-Cookie")
+	if len(cookies) == 0 {
+		return
+	}
+
+	h.Del("Set-Cookie")
+	for _, c := range cookies {
+		if c, ok := s.rewriteCookie(c, host, rt); ok {
+			h.Add("Set-Cookie", c)
+		}
+	}
+}
+
+func (s *Server) rewriteCookie(cookie, host string, rt *route) (string, bool) {
+	attrs := strings.Split(cookie, ";")
+	out := attrs[:1]
+	for _, attr := range attrs[1:] {
+		name, value, _ := strings.Cut(strings.TrimSpace(attr), "=")
+		switch {
+		case strings.EqualFold(name, "Domain"):
+			domain, ok := s.hosts.SreekiDomain(value)
+			if !ok {
+				return "", false
+			}
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				out = append(out, " Domain="+domain)
+			}
+		case strings.EqualFold(name, "Path") && strings.HasPrefix(value, "/"):
+			u := &url.URL{Path: value}
+			rt.mapping.SreekiPath(u)
+			if rt.prefix != "" {
+				hostmap.ReplacePathPrefix(u, "/", rt.prefix)
+			}
+			out = append(out, " "+name+"="+u.EscapedPath())
+		default:
+			out = append(out, attr)
+		}
+	}
+	return strings.Join(out, ";"), true
+}
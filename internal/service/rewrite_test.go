@@ -0,0 +1,178 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/devhou-se/sreetcode/internal/hostmap"
+)
+
+// newRewriteTest returns a server with the default host mappings and mounts, and the route of a
+// request to en.sreekipedia.org, or to the mount with prefix if it isn't empty.
+func newRewriteTest(t *testing.T, prefix string) (*Server, *route) {
+	t.Helper()
+
+	s := &Server{hosts: hostmap.Default()}
+	var err error
+	if s.mounts, err = loadMounts(s.hosts); err != nil {
+		t.Fatal(err)
+	}
+
+	if prefix != "" {
+		for _, rt := range s.mounts {
+			if rt.prefix == prefix {
+				return s, rt
+			}
+		}
+		t.Fatalf("no mount at %s", prefix)
+	}
+	host, m, ok := s.hosts.Upstream("en.sreekipedia.org")
+	if !ok {
+		t.Fatal("no mapping for en.sreekipedia.org")
+	}
+	return s, &route{host: host, mapping: m}
+}
+
+func TestRewriteResponseHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		header http.Header
+		want   http.Header
+	}{
+		{
+			name:   "location on the same site",
+			header: http.Header{"Location": {"https://en.wikipedia.org/wiki/Foo?action=raw"}},
+			want:   http.Header{"Location": {"http://en.sreekipedia.org/sreeki/Foo?action=raw"}},
+		},
+		{
+			name:   "relative location",
+			header: http.Header{"Location": {"/wiki/Foo"}, "Content-Location": {"/w/index.php?title=Foo"}},
+			want:   http.Header{"Location": {"/sreeki/Foo"}, "Content-Location": {"/w/index.php?title=Foo"}},
+		},
+		{
+			name:   "location on another site",
+			header: http.Header{"Location": {"https://de.wikipedia.org/wiki/Foo"}},
+			want:   http.Header{"Location": {"https://de.sreekipedia.org/sreeki/Foo"}},
+		},
+		{
+			name:   "location on a mounted site",
+			header: http.Header{"Location": {"https://en.wiktionary.org/wiki/Foo"}},
+			want:   http.Header{"Location": {"/dict/sreeki/Foo"}},
+		},
+		{
+			name:   "location on an unmapped site",
+			header: http.Header{"Location": {"https://example.com/wiki/Foo"}},
+			want:   http.Header{"Location": {"https://example.com/wiki/Foo"}},
+		},
+		{
+			name:   "location on a mount",
+			prefix: "/dict/",
+			header: http.Header{"Location": {"/wiki/Foo"}},
+			want:   http.Header{"Location": {"/dict/sreeki/Foo"}},
+		},
+		{
+			name: "links",
+			header: http.Header{"Link": {
+				`<https://en.wikipedia.org/wiki/Foo>; rel="canonical"`,
+				`</w/load.php?modules=site>; rel=preload; as=style, <https://upload.wikimedia.org/a.png>; rel=preload`,
+			}},
+			want: http.Header{"Link": {
+				`<http://en.sreekipedia.org/sreeki/Foo>; rel="canonical"`,
+				`</w/load.php?modules=site>; rel=preload; as=style, <https://upload.wikimedia.org/a.png>; rel=preload`,
+			}},
+		},
+		{
+			name:   "refresh",
+			header: http.Header{"Refresh": {"5; url=https://en.wikipedia.org/wiki/Foo"}},
+			want:   http.Header{"Refresh": {"5; url=http://en.sreekipedia.org/sreeki/Foo"}},
+		},
+		{
+			name:   "quoted refresh",
+			header: http.Header{"Refresh": {`0;URL='https://fr.wikipedia.org/wiki/Foo'`}},
+			want:   http.Header{"Refresh": {`0;URL='https://fr.sreekipedia.org/sreeki/Foo'`}},
+		},
+		{
+			name:   "refresh without a url",
+			header: http.Header{"Refresh": {"30"}},
+			want:   http.Header{"Refresh": {"30"}},
+		},
+	}
+
+	for _, tt := range tests {
+		s, rt := newRewriteTest(t, tt.prefix)
+		r := httptest.NewRequest(http.MethodGet, "http://en.sreekipedia.org/sreeki/Foo", nil)
+		h := tt.header.Clone()
+		s.rewriteResponseHeaders(h, r, rt)
+		if !reflect.DeepEqual(h, tt.want) {
+			t.Errorf("%s: rewriteResponseHeaders() = %v, want %v", tt.name, h, tt.want)
+		}
+	}
+}
+
+func TestRewriteResponseHeadersScheme(t *testing.T) {
+	s, rt := newRewriteTest(t, "")
+	r := httptest.NewRequest(http.MethodGet, "http://localhost:8080/sreeki/Foo", nil)
+	r.Header.Set("X-Forwarded-Proto", "https")
+
+	h := http.Header{"Location": {"https://en.wikipedia.org/wiki/Bar"}}
+	s.rewriteResponseHeaders(h, r, rt)
+	if got, want := h.Get("Location"), "https://localhost:8080/sreeki/Bar"; got != want {
+		t.Errorf("Location = %q, want %q", got, want)
+	}
+}
+
+func TestRewriteSetCookies(t *testing.T) {
+	tests := []struct {
+		host    string
+		prefix  string
+		cookies []string
+		want    []string
+	}{
+		{
+			host: "en.sreekipedia.org",
+			cookies: []string{
+				"WMF-Last-Access=01-Jun-2024; Path=/; HttpOnly; Domain=.wikipedia.org",
+				"GeoIP=AU; Path=/; secure; Domain=en.wikipedia.org",
+				"session=1; path=/wiki/; HttpOnly",
+				"api=1; Path=/w/api.php",
+			},
+			want: []string{
+				"WMF-Last-Access=01-Jun-2024; Path=/; HttpOnly; Domain=sreekipedia.org",
+				"GeoIP=AU; Path=/; secure; Domain=en.sreekipedia.org",
+				"session=1; path=/sreeki/; HttpOnly",
+				"api=1; Path=/w/api.php",
+			},
+		},
+		{
+			// A domain that doesn't cover the host makes the cookie host-only.
+			host:    "localhost:8080",
+			cookies: []string{"GeoIP=AU; Path=/; Domain=.wikipedia.org"},
+			want:    []string{"GeoIP=AU; Path=/"},
+		},
+		{
+			// Cookies for other domains are dropped.
+			host:    "en.sreekipedia.org",
+			cookies: []string{"tracker=1; Domain=example.com", "kept=1"},
+			want:    []string{"kept=1"},
+		},
+		{
+			// Cookies of a mounted site are kept to its prefix.
+			host:    "en.sreekipedia.org",
+			prefix:  "/dict/",
+			cookies: []string{"a=1; Path=/", "b=1; Path=/wiki/Foo; Domain=.wiktionary.org"},
+			want:    []string{"a=1; Path=/dict/", "b=1; Path=/dict/sreeki/Foo"},
+		},
+	}
+
+	for _, tt := range tests {
+		s, rt := newRewriteTest(t, tt.prefix)
+		h := http.Header{"Set-Cookie": tt.cookies}
+		s.rewriteSetCookies(h, tt.host, rt)
+		if got := h.Values("Set-Cookie"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("rewriteSetCookies(%q) = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
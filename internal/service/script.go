@@ -0,0 +1,232 @@
+package service
+
+import (
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/devhou-se/sreetcode/internal/ruleset"
+	"github.com/devhou-se/sreetcode/internal/util"
+)
+
+// scriptHost matches a host name in a string in a script, such as "en.wikipedia.org".
+var scriptHost = regexp.MustCompile(`\b(?:[A-Za-z0-9-]+\.)+[A-Za-z]{2,}\b`)
+
+// transformScript rewrites the strings in a script. Host names that are in the host mappings are
+// mapped to their sreeki hosts, and the messages in objects such as
+// {"vector-main-menu-label": "Main menu"} are sreefied if the page is and the ruleset lists their
+// key. The code itself is never changed, and neither is a string if rewriting it could end the
+// string early.
+func transformScript(w io.Writer, r io.Reader, ur *upstreamRequest) error {
+	if ur.rewriteHost == nil && !ur.sreeify {
+		_, err := io.Copy(w, r)
+		return err
+	}
+
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	rs := ruleset.Current()
+	out := rewriteScriptStrings(string(src), func(key, s string, quote byte) string {
+		v := s
+		if ur.rewriteHost != nil {
+			v = scriptHost.ReplaceAllStringFunc(v, ur.rewriteHost)
+		}
+		if ur.sreeify && rs.SreefiesMessage(key) {
+			v = util.Sreefy(v)
+		}
+		if !safeInString(s, v, quote) {
+			return s
+		}
+		return v
+	})
+	_, err = io.WriteString(w, out)
+	return err
+}
+
+// safeInString reports whether the contents of a string literal quoted with quote can be replaced
+// by v: v can't add any characters that would end the string or change how the rest is escaped.
+func safeInString(s, v string, quote byte) bool {
+	for _, c := range []string{string(quote), `\`, "\n", "\r"} {
+		if strings.Count(v, c) != strings.Count(s, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// regexPrecedes are the punctuators a regular expression literal can follow. After anything else, a
+// slash is a division.
+const regexPrecedes = "(,=:[!&|?{};+-*%<>~^"
+
+// regexKeywords are the keywords a regular expression literal can follow.
+var regexKeywords = map[string]bool{
+	"return": true, "typeof": true, "instanceof": true, "in": true, "of": true, "new": true,
+	"delete": true, "void": true, "throw": true, "case": true, "do": true, "else": true,
+	"yield": true, "await": true,
+}
+
+// rewriteScriptStrings passes the contents of each single or double quoted string in a script
+// through rewrite, which also gets the quote character and, if the string is the value of a property
+// with a quoted name, such as in {"key": "value"}, the property's name. Comments, template literals
+// and regular expressions are skipped.
+func rewriteScriptStrings(src string, rewrite func(key, s string, quote byte) string) string {
+	var b strings.Builder
+	last := 0
+
+	// prev is the last character that wasn't space or part of a comment.
+	var prev byte
+	// name is the contents of the last string if it could be a property name, and nameEnd where it
+	// ends.
+	name, nameEnd := "", -1
+
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == '"' || c == '\'':
+			end := stringEnd(src, i)
+			if end < 0 {
+				prev = c
+				i++
+				continue
+			}
+
+			s := src[i+1 : end-1]
+			key := ""
+			if nameEnd >= 0 && strings.TrimSpace(src[nameEnd:i]) == ":" {
+				key = name
+			}
+			if v := rewrite(key, s, c); v != s {
+				b.WriteString(src[last : i+1])
+				b.WriteString(v)
+				last = end - 1
+			}
+
+			name, nameEnd = "", -1
+			if prev == '{' || prev == ',' {
+				name, nameEnd = s, end
+			}
+			prev = c
+			i = end
+			continue
+		case c == '`':
+			i = templateEnd(src, i)
+		case c == '/' && i+1 < len(src) && src[i+1] == '/':
+			if end := strings.IndexByte(src[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(src)
+			}
+			continue
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			if end := strings.Index(src[i+2:], "*/"); end >= 0 {
+				i += end + 4
+			} else {
+				i = len(src)
+			}
+			continue
+		case c == '/' && regexAllowed(src, i, prev):
+			i = regexEnd(src, i)
+		default:
+			i++
+		}
+
+		if !isScriptSpace(c) {
+			prev = src[i-1]
+		}
+	}
+
+	if last == 0 {
+		return src
+	}
+	b.WriteString(src[last:])
+	return b.String()
+}
+
+// stringEnd returns the index after the closing quote of the string starting at i, or -1 if the
+// string isn't closed on the same line.
+func stringEnd(src string, i int) int {
+	quote := src[i]
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case quote:
+			return j + 1
+		case '\n', '\r':
+			return -1
+		}
+	}
+	return -1
+}
+
+// templateEnd returns the index after the template literal starting at i, or the end of the script
+// if it isn't closed.
+func templateEnd(src string, i int) int {
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case '`':
+			return j + 1
+		}
+	}
+	return len(src)
+}
+
+// regexAllowed reports whether the slash at i, which follows prev, starts a regular expression
+// literal rather than being a division.
+func regexAllowed(src string, i int, prev byte) bool {
+	end := i
+	for end > 0 && isScriptSpace(src[end-1]) {
+		end--
+	}
+	start := end
+	for start > 0 && isIdentByte(src[start-1]) {
+		start--
+	}
+	if start < end {
+		return regexKeywords[src[start:end]]
+	}
+	return prev == 0 || strings.IndexByte(regexPrecedes, prev) >= 0
+}
+
+// regexEnd returns the index after the regular expression literal starting at i, with its flags.
+// If the literal isn't closed on the same line, the script can't be followed, so the rest of the
+// line is skipped.
+func regexEnd(src string, i int) int {
+	class := false
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case '[':
+			class = true
+		case ']':
+			class = false
+		case '/':
+			if class {
+				continue
+			}
+			j++
+			for j < len(src) && isIdentByte(src[j]) {
+				j++
+			}
+			return j
+		case '\n', '\r':
+			return j
+		}
+	}
+	return len(src)
+}
+
+func isScriptSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c >= 0x80
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/devhou-se/sreetcode/internal/ruleset"
+)
+
+func TestTransformScriptMessages(t *testing.T) {
+	rs, err := ruleset.Parse([]byte(`
+rules:
+  - match: Wiki
+    replace: Sreeki
+messages:
+  - sitesubtitle
+  - "vector-*"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := ruleset.Current()
+	ruleset.Set(rs)
+	defer ruleset.Set(prev)
+
+	src := `mw.messages.set({"vector-main-menu-label":"Wiki menu","sitesubtitle":"From Wiki",` +
+		`"skin-name":"Wiki","content-type":"Wiki"});var x="Wiki";`
+	want := `mw.messages.set({"vector-main-menu-label":"Sreeki menu","sitesubtitle":"From Sreeki",` +
+		`"skin-name":"Wiki","content-type":"Wiki"});var x="Wiki";`
+
+	var out bytes.Buffer
+	if err := transformScript(&out, strings.NewReader(src), &upstreamRequest{sreeify: true}); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != want {
+		t.Errorf("transformScript() = %s, want %s", out.String(), want)
+	}
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// transformer rewrites a response body of one media type, as requested by ur.
+type transformer func(w io.Writer, r io.Reader, ur *upstreamRequest) error
+
+// transformers are the transformers of the media types whose bodies are rewritten, other than HTML,
+// which fetch sreeifies. Responses of other types are passed through unchanged.
+var transformers = map[string]transformer{
+	"text/css":                 transformCSS,
+	"application/javascript":   transformScript,
+	"application/x-javascript": transformScript,
+	"application/ecmascript":   transformScript,
+	"text/javascript":          transformScript,
+	"text/ecmascript":          transformScript,
+}
+
+// transform starts rewriting the body of p with t. Bodies in an encoding that can't be decoded are
+// passed through unchanged.
+func transform(p *page, ur *upstreamRequest, t transformer) *page {
+	decoded, err := decodeBody(p.body, p.header.Get("Content-Encoding"))
+	if err != nil {
+		slog.Warn(fmt.Sprintf("Not transforming %s: %s", ur.url, err))
+		return p
+	}
+	p.header.Del("Content-Encoding")
+	p.header.Del("Content-Length")
+	p.compress = true
+
+	p.body = pipe(decoded, func(w io.Writer, r io.Reader) error {
+		return t(w, r, ur)
+	})
+	return p
+}
+
+// transformCSS rewrites the links in a style sheet.
+func transformCSS(w io.Writer, r io.Reader, ur *upstreamRequest) error {
+	if ur.rewriteLink == nil {
+		_, err := io.Copy(w, r)
+		return err
+	}
+
+	css, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, rewriteCSSURLs(string(css), ur.rewriteLink))
+	return err
+}
+
+// cssURL matches a url() in CSS, capturing its URL whether it's quoted or not.
+var cssURL = regexp.MustCompile(`(?i)\burl\(\s*(?:"([^"]*)"|'([^']*)'|([^"'\s()]*))\s*\)`)
+
+// cssImport matches an @import given a plain string rather than a url(), capturing its URL.
+var cssImport = regexp.MustCompile(`(?i)@import\s+(?:"([^"]*)"|'([^']*)')`)
+
+// rewriteCSSURLs rewrites the URL in each url() and @import of a style sheet or style attribute.
+func rewriteCSSURLs(css string, rewrite func(string) string) string {
+	css = replaceSubmatches(css, cssURL, rewrite)
+	return replaceSubmatches(css, cssImport, rewrite)
+}
+
+// replaceSubmatches replaces the group of each match of re in s that matched, passing it through
+// rewrite. The rest of the match is kept as it is.
+func replaceSubmatches(s string, re *regexp.Regexp, rewrite func(string) string) string {
+	matches := re.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		for g := 2; g < len(m); g += 2 {
+			if m[g] < 0 {
+				continue
+			}
+			b.WriteString(s[last:m[g]])
+			b.WriteString(rewrite(s[m[g]:m[g+1]]))
+			last = m[g+1]
+			break
+		}
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
@@ -0,0 +1,28 @@
+package service
+
+import "testing"
+
+func TestRewriteCSSURLs(t *testing.T) {
+	tests := []struct {
+		name     string
+		in, want string
+	}{
+		{"unquoted", `a{background:url(/w/a.png)}`, `a{background:url(</w/a.png>)}`},
+		{"single quotes", `a{background:url('/w/a.png')}`, `a{background:url('</w/a.png>')}`},
+		{"double quotes", `a{background:url("/w/a.png")}`, `a{background:url("</w/a.png>")}`},
+		{"spaces and case", `a{background:URL( /w/a.png )}`, `a{background:URL( </w/a.png> )}`},
+		{"several", `a{src:url(a.woff),url("b.woff")}`, `a{src:url(<a.woff>),url("<b.woff>")}`},
+		{"import string", `@import "/w/a.css";@import '/w/b.css' screen;`, `@import "</w/a.css>";@import '</w/b.css>' screen;`},
+		{"import url", `@import url(/w/a.css);`, `@import url(</w/a.css>);`},
+		{"no urls", `a{color:red}/* url */`, `a{color:red}/* url */`},
+		// Names that end in "url" aren't url().
+		{"other function", `a{b:myurl(/w/a.png)}`, `a{b:myurl(/w/a.png)}`},
+	}
+
+	mark := func(link string) string { return "<" + link + ">" }
+	for _, tt := range tests {
+		if got := rewriteCSSURLs(tt.in, mark); got != tt.want {
+			t.Errorf("%s: rewriteCSSURLs(%q) = %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
@@ -4,43 +4,37 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
-	"net/url"
 	"strings"
 	"time"
 )
 
-// sreekiMapper is a URL mapper that maps sreekipedia.org URLs to wikipedia.org URLs.
-func sreekiMapper(h string) (*url.URL, error) {
-	slog.Info(fmt.Sprintf("sreekiMapper: %s", h))
-
-	if h == "" {
-		return nil, fmt.Errorf("empty host")
-	}
-
-	if strings.HasPrefix(h, "localhost") {
-		h = "en.sreekipedia.org"
-	}
-
-	d := fmt.Sprintf("https://%s", h)
-	parts := strings.Split(d, ".")
-	if len(parts) < 2 {
-		return nil, fmt.Errorf("invalid host")
-	}
-
-	np := len(parts)
-	if !(parts[np-2] == "sreekipedia" && parts[np-1] == "org") {
-		return nil, fmt.Errorf("invalid host")
+// hostLanguage returns the language subdomain of a Wikimedia host, such as "en" for
+// en.wikipedia.org.
+func hostLanguage(h string) string {
+	lang, _, ok := strings.Cut(h, ".")
+	if !ok {
+		return ""
 	}
+	return lang
+}
 
-	parts[np-2] = "wikipedia"
-
-	up := strings.Join(parts, ".")
-	u2, err := url.Parse(up)
-	if err != nil {
-		return nil, err
-	}
+// disallowedUserAgents are the user agents whose requests are refused.
+var disallowedUserAgents = []string{
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/114.0.0.0 Safari/537.36",
+}
 
-	return u2, nil
+// blockAgents is a middleware function that blocks requests from disallowed user agents.
+func blockAgents(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, ua := range disallowedUserAgents {
+			if r.UserAgent() == ua {
+				slog.Warn(fmt.Sprintf("Blocked request from disallowed user agent: %s %s", r.Method, r.URL))
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
 }
 
 // middlewareFunc is a middleware function that logs the request.
@@ -1,42 +1,105 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"expvar"
+	"fmt"
 	"io"
 	"log/slog"
+	"mime"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"cloud.google.com/go/storage"
 	"github.com/go-chi/chi/v5"
 
 	"github.com/devhou-se/sreetcode/internal/config"
+	"github.com/devhou-se/sreetcode/internal/hostmap"
 	"github.com/devhou-se/sreetcode/internal/integration/sreeify"
+	"github.com/devhou-se/sreetcode/internal/ruleset"
 	"github.com/devhou-se/sreetcode/internal/util"
 )
 
-var (
-	disallowedUserAgents = []string{
-		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/114.0.0.0 Safari/537.36",
-	}
-)
+// copyBufferSize is the size of the reads made when streaming a response to a client.
+const copyBufferSize = 32 << 10
 
 type Server struct {
 	*http.Server
-	sreeify *sreeify.Client
+	// Admin serves metrics on a port of its own, so they aren't exposed on the proxied sites. It is
+	// nil if no admin port is configured.
+	Admin   *http.Server
+	sreeify sreeify.Sreeifier
+	// fallback is the policy for pages that fail to sreeify.
+	fallback string
+	// cache holds sreeified pages. It is nil if caching is disabled.
+	cache cacheStore
+	// cachePrivate allows pages marked private to be cached.
+	cachePrivate bool
+	// inflight tracks upstream fetches that concurrent identical requests can share.
+	inflight *flightGroup
+	// hosts maps the sreeki hosts to upstream ones.
+	hosts *hostmap.Table
+	// allowedHeaders are the request headers passed on to upstream.
+	allowedHeaders []string
+	// mounts are the sister sites served under a path prefix.
+	mounts []*route
+	// transport makes the requests upstream. http.DefaultTransport is used if it's nil.
+	transport http.RoundTripper
 }
 
 // NewWebServer creates a new web server.
 func NewWebServer(cfg config.Config) (*Server, error) {
-	s := &Server{}
+	if !validFallback(cfg.SreeifyFallback) {
+		return nil, fmt.Errorf("unknown sreeify fallback: %s", cfg.SreeifyFallback)
+	}
+
+	s := &Server{
+		fallback:       cfg.SreeifyFallback,
+		cachePrivate:   cfg.CachePrivate,
+		allowedHeaders: cfg.ForwardHeaders,
+		hosts:          hostmap.Default(),
+	}
 	var err error
 
+	if cfg.HostMappings != "" {
+		s.hosts, err = hostmap.Load(cfg.HostMappings)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Ruleset != "" {
+		if err := ruleset.Use(cfg.Ruleset, cfg.RulesetReload); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mounts, err = loadMounts(s.hosts)
+	if err != nil {
+		return nil, err
+	}
+
 	s.Server, err = s.httpServer(cfg)
 	if err != nil {
 		return nil, err
 	}
+	if cfg.AdminPort != "" {
+		s.Admin = adminServer(cfg)
+	}
+
+	s.sreeify, err = sreeify.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	s.inflight = newFlightGroup()
 
-	s.sreeify, err = sreeify.NewClient(cfg)
+	s.cache, err = newCacheStore(cfg)
 	if err != nil {
 		return nil, err
 	}
@@ -58,21 +121,41 @@ func (s *Server) httpServer(cfg config.Config) (*http.Server, error) {
 	return hs, nil
 }
 
+// adminServer creates the HTTP server for the admin port, which publishes the expvar metrics.
+func adminServer(cfg config.Config) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	return &http.Server{
+		Addr:    ":" + cfg.AdminPort,
+		Handler: mux,
+	}
+}
+
 // router creates a new router with middleware and routes
 func (s *Server) router(cfg config.Config) (*chi.Mux, error) {
 	r := chi.NewRouter()
-	r.Use(middlewareFunc, timerFunc)
+	r.Use(blockAgents, middlewareFunc, timerFunc)
 
 	// Set up routes for specific assets to be replaced.
 	for requestedAsset, replacementAsset := range util.StaticFileOverrides {
 		r.HandleFunc(requestedAsset, assetOverrideHandler(replacementAsset))
 	}
 
-	r.HandleFunc("/*", s.proxyHandler)
+	s.proxyRoutes(r)
 
 	return r, nil
 }
 
+// proxyRoutes adds the routes of the proxied sites to r.
+func (s *Server) proxyRoutes(r chi.Router) {
+	// Sister sites are mounted under a path prefix on every host.
+	for _, rt := range s.mounts {
+		r.HandleFunc(rt.prefix+"*", s.mountHandler(rt))
+	}
+
+	r.HandleFunc("/*", s.proxyHandler)
+}
+
 // ReplacedAssetHandler handles serving replaced assets from a specified location.
 func assetOverrideHandler(assetLocation string) http.HandlerFunc {
 	ctx := context.Background()
@@ -104,132 +187,381 @@ func assetOverrideHandler(assetLocation string) http.HandlerFunc {
 
 // proxyHandler is a handler that proxies requests to the appropriate URL.
 func (s *Server) proxyHandler(w http.ResponseWriter, r *http.Request) {
-	u, err := sreekiMapper(r.Host)
-	if err != nil {
-		slog.Error("Error mapping URL")
+	host, m, ok := s.hosts.Upstream(r.Host)
+	if !ok {
+		slog.Error(fmt.Sprintf("Error mapping host: %s", r.Host))
 		http.Error(w, "Not found", http.StatusNotFound)
 		return
+	}
 
-// blockAgents is a middleware function that blocks requests from disallowed user agents.
-func blockAgents(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		for _, ua := range disallowedUserAgents {
-			if r.UserAgent() == ua {
-				slog.Info(fmt.Sprintf("Disallowed user agent with request: %s %s", r.Method, r.URL))
-				slog.Warn(fmt.Sprintf("Blocked request from disallowed user agent: %s", r.UserAgent()))
-				http.Error(w, "Forbidden", http.StatusForbidden)
-				return
-			}
+	s.proxy(w, r, &route{host: host, mapping: m})
+}
+
+// proxy proxies a request to the site rt routes it to.
+func (s *Server) proxy(w http.ResponseWriter, r *http.Request, rt *route) {
+	m := rt.mapping
+
+	path := *r.URL
+	if rt.prefix != "" {
+		hostmap.ReplacePathPrefix(&path, rt.prefix, "/")
+	}
+
+	// Links in pages and upstream redirects are mapped to sreeki paths, but links to upstream paths
+	// can still come from elsewhere, such as bookmarks and other sites.
+	target := path
+	if m.SreekiPath(&target) {
+		if rt.prefix != "" {
+			hostmap.ReplacePathPrefix(&target, "/", rt.prefix)
 		}
-		// temp
-		slog.Info(fmt.Sprintf("allowed user agent: %s", r.UserAgent()))
-		next.ServeHTTP(w, r)
-	})
+		http.Redirect(w, r, target.RequestURI(), http.StatusTemporaryRedirect)
+		return
+	}
+
+	u2 := path
+	u2.Scheme = m.UpstreamScheme()
+	u2.Host = rt.host
+	m.UpstreamPath(&u2)
+
+	ur := &upstreamRequest{
+		method:        r.Method,
+		url:           &u2,
+		header:        make(http.Header),
+		body:          r.Body,
+		contentLength: r.ContentLength,
+		lang:          hostLanguage(rt.host),
+		sreeify:       m.Sreeifies(),
+		prefix:        rt.prefix,
+	}
+	ur.rewriteLink = func(link string) string {
+		return s.linkURL(link, rt)
+	}
+	ur.rewriteHost = func(host string) string {
+		if h, _, ok := s.hosts.Sreeki(host); ok {
+			return h
+		}
+		return host
+	}
+	s.forwardHeaders(ur.header, r)
+	setForwardedHeaders(ur.header, r)
+
+	var p *page
+	var err error
+	if cacheableRequest(r) {
+		// The shared fetch can outlive r, so it mustn't read r's body. GET requests don't need one.
+		// The page is shared with every client, so none of r's cookies go upstream. They aren't a
+		// session's, but may hold anonymous preferences, which are lost: keying the cache by them
+		// would give every visitor their own copy, as upstream sets tracking cookies on everyone.
+		ur.key = cacheKey(ur, r.Header.Get("Accept-Language"))
+		ur.body, ur.contentLength = nil, 0
+		ur.header.Del("Cookie")
+		p, err = s.fetchShared(r.Context(), ur)
+	} else {
+		p, err = s.fetch(r.Context(), ur)
+	}
+	if err != nil {
+		var pe *proxyError
+		if errors.As(err, &pe) {
+			http.Error(w, pe.msg, pe.status)
+		} else {
+			http.Error(w, "Error making request", http.StatusInternalServerError)
+		}
+		slog.Error(err.Error())
+		return
+	}
+
+	s.rewriteResponseHeaders(p.header, r, rt)
+	p.write(w, r)
 }
 
-// middlewareFunc is a middleware function that logs the request.
-func middlewareFunc(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("%s %s\n", r.Method, r.URL)
-		next.ServeHTTP(w, r)
-	})
+// upstreamRequest is a request to be proxied upstream.
+type upstreamRequest struct {
+	method string
+	url    *url.URL
+	header http.Header
+	body   io.Reader
+	// contentLength is the length of body, or -1 if it's unknown.
+	contentLength int64
+	// lang is the language of the wiki being requested.
+	lang string
+	// sreeify is false if HTML pages are passed through with only their links rewritten.
+	sreeify bool
+	// prefix is the path prefix of the mounted site being requested, if any.
+	prefix string
+	// rewriteLink rewrites the links in HTML pages and style sheets. It is nil if they're left
+	// unchanged.
+	rewriteLink func(string) string
+	// rewriteHost maps the upstream host names in scripts. It is nil if they're left unchanged.
+	rewriteHost func(string) string
+	// key identifies the page in the cache. It is empty if the response mustn't be cached or shared.
+	key string
 }
 
-// timerFunc is a middleware function that times processing of the request.
-func timerFunc(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		next.ServeHTTP(w, r)
-		log.Printf("Request took %s\n", time.Now().Sub(start))
+// rewriteLinks returns an HTML body with its links rewritten by rewriteLink, if it's set.
+func (ur *upstreamRequest) rewriteLinks(body io.ReadCloser) io.ReadCloser {
+	if ur.rewriteLink == nil {
+		return body
+	}
+	return pipe(body, func(w io.Writer, r io.Reader) error {
+		return rewriteLinks(w, r, ur.rewriteLink)
 	})
 }
 
-// sreekiMapper is a URL mapper that maps sreekipedia.org URLs to wikipedia.org URLs.
-func sreekiMapper(h string) (*url.URL, bool) {
-	slog.Info(fmt.Sprintf("sreekiMapper: %s", h))
+// page is an upstream response, being sreeified if it's HTML, ready to be written to a client.
+type page struct {
+	status int
+	header http.Header
+	body   io.ReadCloser
+	// shared is true if the body can be shared between requests. Pages that aren't HTML are
+	// streamed straight from upstream, so they aren't.
+	shared bool
+	// compress is true if the body has been rewritten and should be compressed for the client.
+	// Other bodies are sent with the encoding upstream gave them.
+	compress bool
+}
 
-	if h == "" {
-		return nil, false
+// write streams the page to the client that made r, encoded in a way it accepts.
+func (p *page) write(w http.ResponseWriter, r *http.Request) {
+	defer p.body.Close()
+
+	var body io.Reader = p.body
+	if enc := p.header.Get("Content-Encoding"); !acceptsEncoding(r, enc) {
+		d, err := decodeBody(p.body, enc)
+		if err == nil {
+			body = d
+			p.header.Del("Content-Encoding")
+			p.header.Del("Content-Length")
+		}
 	}
 
-	u2 := r.URL
-	u2.Scheme = u.Scheme
-	u2.Host = u.Host
+	for h, values := range p.header {
+		for _, v := range values {
+			w.Header().Add(h, v)
+		}
+	}
 
-	if strings.HasPrefix(u2.Path, "/wiki/") {
-		u2.Path = strings.Replace(u2.Path, "/wiki/", "/sreeki/", 1)
-		http.Redirect(w, r, u2.Path, http.StatusTemporaryRedirect)
+	rc := http.NewResponseController(w)
+	var out io.Writer = w
+	flush := func() { rc.Flush() }
+
+	if p.compress {
+		addVary(w.Header(), "Accept-Encoding")
+		if enc := preferredEncoding(r); enc != "" {
+			w.Header().Set("Content-Encoding", enc)
+			w.Header().Del("Content-Length")
+
+			c := newCompressor(w, enc)
+			defer c.Close()
+			out = c
+			flush = func() {
+				c.Flush()
+				rc.Flush()
+			}
+		}
 	}
 
-	if strings.HasPrefix(u2.Path, "/sreeki/") {
-		u2.Path = strings.Replace(u2.Path, "/sreeki/", "/wiki/", 1)
+	w.WriteHeader(p.status)
+
+	if err := copyFlush(out, body, flush); err != nil {
+		slog.Error(fmt.Sprintf("Error writing response: %s", err))
+		// The status has already been sent, so abort the response to stop the client mistaking it
+		// for a complete one.
+		panic(http.ErrAbortHandler)
 	}
+}
 
-	req, err := http.NewRequest(r.Method, u2.String(), r.Body)
-	if err != nil {
-		return nil, false
+// copyFlush copies src to w, flushing after each write so the client gets data as soon as it's
+// available.
+func copyFlush(w io.Writer, src io.Reader, flush func()) error {
+	buf := make([]byte, copyBufferSize)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return werr
+			}
+			flush()
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
 	}
+}
 
-	return u2, true
+// proxyError is a failure to proxy a request, with the status and message to report it with.
+type proxyError struct {
+	status int
+	msg    string
+	err    error
 }
 
-func (s *Server) newRouter(f func(string) (*url.URL, bool)) *chi.Mux {
-	router := chi.NewRouter()
+func (e *proxyError) Error() string {
+	return fmt.Sprintf("%s: %s", e.msg, e.err)
+}
 
-	router.Use(blockAgents, middlewareFunc, timerFunc)
+func (e *proxyError) Unwrap() error {
+	return e.err
+}
 
-	httpClient := &http.Client{}
+// fetch requests a page from upstream and starts sreeifying it and rewriting its links if it's
+// HTML, or transforming it if its type has a transformer. Pages with a cache key are served from
+// the cache while fresh, revalidated once stale, and stored once sreeified.
+//
+// HTML is sreeified as it streams in if the sreeifier supports it. Otherwise the whole page is
+// read and sreeified before fetch returns, so the fallback policy can still decide the response.
+func (s *Server) fetch(ctx context.Context, ur *upstreamRequest) (*page, error) {
+	var cached *cacheEntry
+	if s.cache != nil && ur.key != "" {
+		if e, ok := s.cache.Get(ur.key); ok {
+			if e.fresh(time.Now()) {
+				return e.page(cacheHit), nil
+			}
+			cached = e
+		}
+	}
 
-	for original, replaced := range util.URLMappings {
-		originalUrl, _ := url.Parse(original)
-		router.Handle(fmt.Sprintf("%s*", replaced), http.StripPrefix(replaced, s.ProxyHandler(httpClient, originalUrl)))
+	req, err := http.NewRequestWithContext(ctx, ur.method, ur.url.String(), ur.body)
+	if err != nil {
+		return nil, &proxyError{http.StatusInternalServerError, "Error creating request", err}
+	}
+	if ur.header != nil {
+		req.Header = ur.header.Clone()
+	}
+	req.ContentLength = ur.contentLength
+	// Pages are decoded here rather than by the transport, so other responses can be passed
+	// through to clients still compressed.
+	req.Header.Set("Accept-Encoding", upstreamEncodings)
+	if cached != nil {
+		cached.addValidators(req.Header)
 	}
 
-	client := &http.Client{}
+	client := &http.Client{
+		Transport: s.transport,
+		// Redirects are passed on to the client, with their Location mapped to the sreeki site.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
 
+	proxyMetrics.Add("upstream_fetches", 1)
 	resp, err := client.Do(req)
 	if err != nil {
-		http.Error(w, "Error making request", http.StatusInternalServerError)
-		slog.Error("Error making request: %s", err)
-		return
-	// Set up routes for specific assets to be replaced.
-	mappings := map[string]string{
-		"/static/images/mobile/copyright/sreekipedia-wordmark-en.svg": "sreekipedia.org/sreekipedia-wordmark-en.svg",
-		"/static/images/mobile/copyright/sreekipedia-tagline-en.svg":  "sreekipedia.org/tagling.svg",
-		"/static/favicon/sreekipedia.ico":                             "sreekipedia.org/sreeki.ico",
-		"/robots.txt":                                                 "sreekipedia.org/robots.txt",
+		return nil, &proxyError{http.StatusInternalServerError, "Error making request", err}
 	}
-	for requestedAsset, replacementAsset := range mappings {
-		router.HandleFunc(requestedAsset, ReplacedAssetHandler(replacementAsset))
+
+	if cached != nil && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		cached = cached.revalidated(resp.Header, time.Now())
+		s.cache.Set(ur.key, cached)
+		return cached.page(cacheRevalidated), nil
 	}
-	defer resp.Body.Close()
 
-	for h, values := range resp.Header {
-		for _, v := range values {
-			w.Header().Add(h, v)
-		}
+	p := &page{
+		status: resp.StatusCode,
+		header: resp.Header.Clone(),
+		body:   resp.Body,
 	}
+	removeHopHeaders(p.header)
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		http.Error(w, "Error reading response", http.StatusInternalServerError)
-		slog.Error("Error reading response: %s", err)
-		return
+	// Responses without a body have nothing to sreeify or transform.
+	if !hasBody(ur.method, resp.StatusCode) {
+		return p, nil
 	}
 
-	if !strings.Contains(resp.Header.Get("Content-Type"), "text/html") {
-		w.WriteHeader(resp.StatusCode)
-		w.Write(body)
-		return
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+	t, transformed := transformers[mediaType]
+	rewriteHTML := strings.Contains(contentType, "text/html") && (ur.sreeify || ur.rewriteLink != nil)
+
+	// Part of a page can't be rewritten, and the range would be of the original rather than the
+	// rewritten page anyway. The whole page is fetched instead, which answers a range request too.
+	if resp.StatusCode == http.StatusPartialContent && (transformed || rewriteHTML) && ur.header.Get("Range") != "" {
+		resp.Body.Close()
+		ur.header.Del("Range")
+		ur.header.Del("If-Range")
+		return s.fetch(ctx, ur)
+	}
+
+	if transformed {
+		return transform(p, ur, t), nil
+	}
+	if !rewriteHTML {
+		return p, nil
 	}
 
-	modifiedBody, err := s.sreeify.Sreeify(body)
+	// Pages in an encoding that can't be decoded are passed through like any other response.
+	decoded, err := decodeBody(resp.Body, resp.Header.Get("Content-Encoding"))
 	if err != nil {
-		http.Error(w, "Error sreeifying response", http.StatusInternalServerError)
-		slog.Error("Error sreeifying response: %s", err)
-		return
+		slog.Warn(fmt.Sprintf("Not sreeifying %s: %s", ur.url, err))
+		return p, nil
+	}
+	p.header.Del("Content-Encoding")
+	p.header.Del("Content-Length")
+	p.shared = true
+	p.compress = true
+
+	// Links are rewritten on pages that aren't sreeified too, so navigation stays on the proxy.
+	if !ur.sreeify {
+		p.body = ur.rewriteLinks(decoded)
+		return p, nil
+	}
+
+	ctx = sreeify.WithMetadata(ctx, sreeify.Metadata{
+		ContentType: mediaType,
+		Charset:     params["charset"],
+		SourceURL:   ur.url.String(),
+		Language:    ur.lang,
+	})
+
+	cache := func(body []byte) {
+		if e := newCacheEntry(p.status, p.header, body, time.Now(), s.cachePrivate); e != nil {
+			s.cache.Set(ur.key, e)
+		}
+	}
+	cacheable := s.cache != nil && ur.key != ""
+	if cacheable {
+		p.header.Set(cacheHeader, cacheMiss)
+	}
+
+	sreeified := true
+	if ss, ok := s.sreeify.(sreeify.StreamSreeifier); ok {
+		p.body, sreeified, err = s.sreeifyPageStream(ctx, ss, decoded)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		body, err := io.ReadAll(decoded)
+		decoded.Close()
+		if err != nil {
+			return nil, &proxyError{http.StatusInternalServerError, "Error reading response", err}
+		}
+
+		var modifiedBody []byte
+		modifiedBody, sreeified, err = s.sreeifyPage(ctx, body)
+		if err != nil {
+			return nil, &proxyError{sreeifyErrorStatus(err), "Error sreeifying response", err}
+		}
+		p.body = io.NopCloser(bytes.NewReader(modifiedBody))
+		if ur.rewriteLink == nil {
+			p.header.Set("Content-Length", strconv.Itoa(len(modifiedBody)))
+		}
+	}
+
+	// Links are rewritten here rather than by the sreeifier, so whichever backend is used, and the
+	// fallback, leave the same links.
+	p.body = ur.rewriteLinks(p.body)
+
+	// Pages served by a fallback aren't cached, so they are sreeified properly once the sreeifier
+	// recovers.
+	if cacheable {
+		if sreeified {
+			p.body = &cachingReader{ReadCloser: p.body, done: cache}
+		} else {
+			p.header.Del(cacheHeader)
+		}
 	}
 
-	w.WriteHeader(resp.StatusCode)
-	w.Write(modifiedBody)
+	return p, nil
 }
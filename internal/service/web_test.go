@@ -0,0 +1,143 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/devhou-se/sreetcode/internal/integration/sreeify"
+)
+
+// newFetchTest returns a server that proxies upstream with a noop sreeifier, and a function making
+// a shareable request for the page at path.
+func newFetchTest(t *testing.T, upstream http.Handler) (*Server, func(path string) *upstreamRequest) {
+	t.Helper()
+	ts := httptest.NewServer(upstream)
+	t.Cleanup(ts.Close)
+
+	s := &Server{
+		sreeify:  sreeify.Noop{},
+		fallback: fallbackOriginal,
+		inflight: newFlightGroup(),
+	}
+	request := func(path string) *upstreamRequest {
+		u, err := url.Parse(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &upstreamRequest{method: http.MethodGet, url: u, header: make(http.Header), sreeify: true, key: path}
+	}
+	return s, request
+}
+
+func TestFetchRangeOfRewrittenPage(t *testing.T) {
+	content := map[string]string{
+		"/page":  "<p>Wikipedia, the free encyclopedia</p>",
+		"/style": "a { background: url(/w/x.png) }",
+		"/image": "\x89PNG image data",
+	}
+	types := map[string]string{"/page": "text/html", "/style": "text/css", "/image": "image/png"}
+	s, request := newFetchTest(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", types[r.URL.Path])
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader([]byte(content[r.URL.Path])))
+	}))
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		// Rewritten pages are fetched whole.
+		{"/page", http.StatusOK},
+		{"/style", http.StatusOK},
+		// Anything else is passed through as upstream sent it.
+		{"/image", http.StatusPartialContent},
+	}
+	for _, tt := range tests {
+		ur := request(tt.path)
+		ur.key = ""
+		ur.header.Set("Range", "bytes=0-3")
+		p, err := s.fetch(context.Background(), ur)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(p.body)
+		p.body.Close()
+
+		if p.status != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.path, p.status, tt.status)
+		}
+		if tt.status == http.StatusOK && (len(body) < len(content[tt.path]) || p.header.Get("Content-Range") != "") {
+			t.Errorf("%s: got %q with Content-Range %q, want the whole page", tt.path, body, p.header.Get("Content-Range"))
+		}
+		if tt.status == http.StatusPartialContent && string(body) != content[tt.path][:4] {
+			t.Errorf("%s: got %q, want %q", tt.path, body, content[tt.path][:4])
+		}
+	}
+}
+
+// sreeifierFunc is a Sreeifier that can't stream, calling itself.
+type sreeifierFunc func(ctx context.Context, input []byte) ([]byte, error)
+
+func (f sreeifierFunc) Sreeify(ctx context.Context, input []byte) ([]byte, error) {
+	return f(ctx, input)
+}
+
+func TestFetchWithoutStreaming(t *testing.T) {
+	const page = "<p>Wikipedia, the free encyclopedia</p>"
+	s, request := newFetchTest(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, page)
+	}))
+
+	tests := []struct {
+		name     string
+		err      error
+		fallback string
+		// status is the status of the error returned by fetch, or 0 if the page is served.
+		status int
+		want   string
+	}{
+		{name: "sreeified", fallback: fallbackError, want: "<p>Sreekipedia, the Sree encyclopedia</p>"},
+		{name: "falls back", err: errors.New("failed"), fallback: fallbackOriginal, want: page},
+		{name: "fails", err: errors.New("failed"), fallback: fallbackError, status: http.StatusInternalServerError},
+		{name: "times out", err: &sreeify.TimeoutError{ID: "1"}, fallback: fallbackError, status: http.StatusGatewayTimeout},
+		{name: "circuit open", err: sreeify.ErrCircuitOpen, fallback: fallbackError, status: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		s.fallback = tt.fallback
+		s.sreeify = sreeifierFunc(func(ctx context.Context, input []byte) ([]byte, error) {
+			if tt.err != nil {
+				return nil, tt.err
+			}
+			return sreeify.Local{}.Sreeify(ctx, input)
+		})
+
+		p, err := s.fetch(context.Background(), request("/page"))
+		if tt.status != 0 {
+			var pe *proxyError
+			if !errors.As(err, &pe) || pe.status != tt.status {
+				t.Errorf("%s: fetch() error = %v, want status %d", tt.name, err, tt.status)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: fetch() error = %v", tt.name, err)
+		}
+		body, _ := io.ReadAll(p.body)
+		p.body.Close()
+
+		if string(body) != tt.want {
+			t.Errorf("%s: body = %q, want %q", tt.name, body, tt.want)
+		}
+		if got, want := p.header.Get("Content-Length"), strconv.Itoa(len(tt.want)); got != want {
+			t.Errorf("%s: Content-Length = %q, want %q", tt.name, got, want)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package sreeifier
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/devhou-se/sreetcode/internal/gen"
+	"github.com/devhou-se/sreetcode/internal/util"
+)
+
+const (
+	chunkSize = 1024 * 1024 // 1MB
+	// maxDocumentSize bounds the documents accepted on a stream, so that a client can't make the
+	// server allocate more than that for one.
+	maxDocumentSize = 64 * chunkSize
+	maxParts        = maxDocumentSize / chunkSize
+	// defaultStaleAfter is how long a partly received document is kept without receiving another
+	// part.
+	defaultStaleAfter = time.Minute
+)
+
+// Server is a Go implementation of both versions of the Sreeification gRPC service.
+type Server struct {
+	pb.UnimplementedSreeificationServiceServer
+	pb.UnimplementedSreeificationServiceV2Server
+
+	// sem bounds the documents from v1 streams being sreeified at once, across all streams.
+	sem        chan struct{}
+	staleAfter time.Duration
+}
+
+// NewServer creates a new Sreeification server, sreeifying as many documents from v1 streams at
+// once as there are CPUs to run them.
+func NewServer() *Server {
+	return &Server{
+		sem:        make(chan struct{}, runtime.GOMAXPROCS(0)),
+		staleAfter: defaultStaleAfter,
+	}
+}
+
+// Sreeify reassembles chunked payloads from the stream, sreeifies each complete document and
+// streams the result back in chunks under the same id. Pings are echoed back as they arrive.
+//
+// While the server is sreeifying as many documents as it can, the stream isn't read until one of
+// them is done, so clients are pushed back on rather than piling up work. Partly received
+// documents are checked for staleness on a ticker, so they are evicted even if nothing else
+// arrives on the stream.
+func (s *Server) Sreeify(stream pb.SreeificationService_SreeifyServer) error {
+	// Sends may come from several goroutines, which a gRPC stream doesn't allow concurrently.
+	var mu sync.Mutex
+	send := func(resp *pb.Sreesponse) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return stream.Send(resp)
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	// The stream is read in its own goroutine so that the ticker can fire while a read is blocked.
+	// Its context is cancelled when this returns, ending the read.
+	reqs := make(chan *pb.Sreequest)
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			select {
+			case reqs <- req:
+			case <-stream.Context().Done():
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(s.staleAfter)
+	defer ticker.Stop()
+
+	docs := make(map[string]*document)
+
+	for {
+		var req *pb.Sreequest
+		select {
+		case err := <-recvErr:
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		case now := <-ticker.C:
+			if err := evictStale(docs, now.Add(-s.staleAfter), send); err != nil {
+				return err
+			}
+			continue
+		case req = <-reqs:
+		}
+
+		switch x := req.GetData().(type) {
+		case *pb.Sreequest_Ping:
+			err := send(&pb.Sreesponse{
+				Data: &pb.Sreesponse_Ping{
+					Ping: x.Ping,
+				},
+			})
+			if err != nil {
+				return err
+			}
+		case *pb.Sreequest_Payload:
+			payload := x.Payload
+			id := payload.GetId()
+			total, part := payload.GetTotalParts(), payload.GetPart()
+
+			var desc string
+			doc, ok := docs[id]
+			switch {
+			case total <= 0 || part < 0 || part >= total:
+				desc = fmt.Sprintf("invalid part %d of %d", part, total)
+			case total > maxParts:
+				desc = fmt.Sprintf("%d parts is more than the limit of %d", total, maxParts)
+			case ok && len(doc.parts) != int(total):
+				desc = fmt.Sprintf("part %d of %d for a document of %d parts", part, total, len(doc.parts))
+			case ok && doc.size+len(payload.GetData()) > maxDocumentSize:
+				desc = fmt.Sprintf("document is larger than the limit of %d bytes", maxDocumentSize)
+			}
+			if desc != "" {
+				slog.Error(fmt.Sprintf("Error in request %s: %s", id, desc))
+				delete(docs, id)
+				if err := send(errorResponse(id, pb.Error_INVALID_DOCUMENT, desc)); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if !ok {
+				doc = newDocument(int(total))
+				docs[id] = doc
+			}
+			doc.add(int(part), payload.GetData())
+
+			if doc.remaining > 0 {
+				continue
+			}
+			parts := doc.parts
+			delete(docs, id)
+
+			select {
+			case s.sem <- struct{}{}:
+			case <-stream.Context().Done():
+				return stream.Context().Err()
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-s.sem }()
+				if err := s.process(id, parts, send); err != nil {
+					slog.Error(fmt.Sprintf("Error sending response %s: %s", id, err))
+				}
+			}()
+		}
+	}
+}
+
+// SreeifyDocument sreeifies a whole document in a single call.
+func (s *Server) SreeifyDocument(_ context.Context, doc *pb.Document) (*pb.Document, error) {
+	logDocument(doc)
+
+	output, err := util.SreefyHTML(doc.GetData())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "sreeifying document: %s", err)
+	}
+
+	return &pb.Document{
+		Metadata: doc.GetMetadata(),
+		Data:     output,
+	}, nil
+}
+
+// SreeifyDocumentStream sreeifies a document, streaming the result back in chunks as it is
+// produced.
+func (s *Server) SreeifyDocumentStream(doc *pb.Document, stream pb.SreeificationServiceV2_SreeifyDocumentStreamServer) error {
+	logDocument(doc)
+
+	w := bufio.NewWriterSize(chunkWriter{stream}, chunkSize)
+	if err := util.SreefyHTMLStream(w, bytes.NewReader(doc.GetData())); err != nil {
+		return status.Errorf(codes.InvalidArgument, "sreeifying document: %s", err)
+	}
+	return w.Flush()
+}
+
+// chunkWriter sends everything written to it as document chunks.
+type chunkWriter struct {
+	stream pb.SreeificationServiceV2_SreeifyDocumentStreamServer
+}
+
+func (w chunkWriter) Write(p []byte) (int, error) {
+	// Send marshals the message before returning, so p can be reused by the caller afterwards.
+	if err := w.stream.Send(&pb.DocumentChunk{Data: p}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func logDocument(doc *pb.Document) {
+	md := doc.GetMetadata()
+	slog.Info(fmt.Sprintf("Received document %s (%s, %s) with %d bytes", md.GetSourceUrl(), md.GetContentType(), md.GetLanguage(), len(doc.GetData())))
+}
+
+// process sreeifies a reassembled document and sends it back in chunks.
+func (s *Server) process(id string, parts [][]byte, send func(*pb.Sreesponse) error) error {
+	var input []byte
+	for _, p := range parts {
+		input = append(input, p...)
+	}
+	slog.Info(fmt.Sprintf("Received request %s with %d parts and %d bytes", id, len(parts), len(input)))
+
+	output, err := util.SreefyHTML(input)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Error sreeifying request %s: %s", id, err))
+		return send(errorResponse(id, pb.Error_INVALID_DOCUMENT, err.Error()))
+	}
+
+	chunks := chunkData(output)
+	for i, chunk := range chunks {
+		err := send(&pb.Sreesponse{
+			Data: &pb.Sreesponse_Payload{
+				Payload: &pb.Payload{
+					Id:         id,
+					Part:       int32(i),
+					TotalParts: int32(len(chunks)),
+					Data:       chunk,
+				},
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	slog.Info(fmt.Sprintf("Sent response %s with %d parts and %d bytes", id, len(chunks), len(output)))
+
+	return nil
+}
+
+// errorResponse tells the client that request id failed.
+func errorResponse(id string, code pb.Error_Code, description string) *pb.Sreesponse {
+	return &pb.Sreesponse{
+		Data: &pb.Sreesponse_Error{
+			Error: &pb.Error{
+				Id:          id,
+				Code:        code,
+				Description: description,
+			},
+		},
+	}
+}
+
+// evictStale drops the documents that haven't received a part since before, telling the client
+// they failed. Their parts would otherwise be held until the stream ends.
+func evictStale(docs map[string]*document, before time.Time, send func(*pb.Sreesponse) error) error {
+	for id, doc := range docs {
+		if !doc.updated.Before(before) {
+			continue
+		}
+		delete(docs, id)
+		desc := fmt.Sprintf("%d of %d parts received before timing out", len(doc.parts)-doc.remaining, len(doc.parts))
+		slog.Error(fmt.Sprintf("Error in request %s: %s", id, desc))
+		if err := send(errorResponse(id, pb.Error_INVALID_DOCUMENT, desc)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// document collects the parts of a chunked payload as they arrive.
+type document struct {
+	parts     [][]byte
+	seen      []bool
+	remaining int
+	// size is the number of bytes received, and updated is when a part was last received.
+	size    int
+	updated time.Time
+}
+
+func newDocument(total int) *document {
+	return &document{
+		parts:     make([][]byte, total),
+		seen:      make([]bool, total),
+		remaining: total,
+	}
+}
+
+// add stores a part of the document. Parts may arrive in any order and duplicates are ignored.
+func (d *document) add(part int, data []byte) {
+	d.updated = time.Now()
+	if part >= len(d.parts) || d.seen[part] {
+		return
+	}
+	d.parts[part] = data
+	d.seen[part] = true
+	d.remaining--
+	d.size += len(data)
+}
+
+// chunkData splits b into chunks of at most chunkSize. An empty document is sent as a single empty
+// chunk, which the client counts as the whole response even though its data arrives as nil.
+func chunkData(b []byte) [][]byte {
+	if len(b) == 0 {
+		return [][]byte{{}}
+	}
+
+	var bs [][]byte
+	for i := 0; i < len(b); i += chunkSize {
+		end := i + chunkSize
+		if end > len(b) {
+			end = len(b)
+		}
+		bs = append(bs, b[i:end])
+	}
+	return bs
+}
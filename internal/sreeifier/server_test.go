@@ -0,0 +1,220 @@
+package sreeifier
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	pb "github.com/devhou-se/sreetcode/internal/gen"
+	"github.com/devhou-se/sreetcode/internal/grpctest"
+	"github.com/devhou-se/sreetcode/internal/integration/sreeify"
+	"github.com/devhou-se/sreetcode/internal/util"
+)
+
+// newTestClient starts srv on an in-memory connection, registering the v2 service only if v2 is
+// true, and returns a client connected to it.
+func newTestClient(t *testing.T, v2 bool) *sreeify.Client {
+	t.Helper()
+
+	conn := grpctest.Serve(t, func(s *grpc.Server) {
+		srv := NewServer()
+		pb.RegisterSreeificationServiceServer(s, srv)
+		if v2 {
+			pb.RegisterSreeificationServiceV2Server(s, srv)
+		}
+	})
+
+	c, err := sreeify.NewClientConn(conn, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestClientAgainstServer(t *testing.T) {
+	page := "<html><body><p>Wikipedia, the free encyclopedia</p></body></html>"
+	docs := map[string]string{
+		"empty": "",
+		"small": page,
+		// Larger than a chunk, and than what protocol v2 sends whole.
+		"large": strings.Repeat(page, 4*1024*1024/len(page)),
+	}
+
+	for _, v2 := range []bool{false, true} {
+		c := newTestClient(t, v2)
+		for name, doc := range docs {
+			want, err := util.SreefyHTML([]byte(doc))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			got, err := c.Sreeify(ctx, []byte(doc))
+			cancel()
+			if err != nil {
+				t.Errorf("v2=%t %s: Sreeify() error = %v", v2, name, err)
+			} else if !bytes.Equal(got, want) {
+				t.Errorf("v2=%t %s: Sreeify() returned %d bytes, want %d", v2, name, len(got), len(want))
+			}
+
+			var buf bytes.Buffer
+			ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+			err = c.SreeifyStream(ctx, &buf, strings.NewReader(doc))
+			cancel()
+			if err != nil {
+				t.Errorf("v2=%t %s: SreeifyStream() error = %v", v2, name, err)
+			} else if !bytes.Equal(buf.Bytes(), want) {
+				t.Errorf("v2=%t %s: SreeifyStream() wrote %d bytes, want %d", v2, name, buf.Len(), len(want))
+			}
+		}
+	}
+}
+
+func TestServerRejectsOversizedDocuments(t *testing.T) {
+	conn := grpctest.Serve(t, func(s *grpc.Server) {
+		pb.RegisterSreeificationServiceServer(s, NewServer())
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stream, err := pb.NewSreeificationServiceClient(conn).Sreeify(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payloads := []*pb.Payload{
+		{Id: "huge", Part: 0, TotalParts: math.MaxInt32},
+		{Id: "mismatched", Part: 0, TotalParts: 2},
+		{Id: "mismatched", Part: 1, TotalParts: 3},
+	}
+	for _, p := range payloads {
+		if err := stream.Send(&pb.Sreequest{Data: &pb.Sreequest_Payload{Payload: p}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, id := range []string{"huge", "mismatched"} {
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e := resp.GetError(); e.GetId() != id || e.GetCode() != pb.Error_INVALID_DOCUMENT {
+			t.Errorf("response = %v, want an INVALID_DOCUMENT error for %s", resp, id)
+		}
+	}
+}
+
+func TestEvictStale(t *testing.T) {
+	now := time.Now()
+	docs := map[string]*document{
+		"stale": newDocument(2),
+		"fresh": newDocument(2),
+	}
+	docs["stale"].add(0, []byte("x"))
+	docs["stale"].updated = now.Add(-2 * defaultStaleAfter)
+	docs["fresh"].add(0, []byte("x"))
+
+	var sent []*pb.Sreesponse
+	err := evictStale(docs, now.Add(-defaultStaleAfter), func(resp *pb.Sreesponse) error {
+		sent = append(sent, resp)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := docs["stale"]; ok {
+		t.Error("stale document wasn't evicted")
+	}
+	if _, ok := docs["fresh"]; !ok {
+		t.Error("fresh document was evicted")
+	}
+	if len(sent) != 1 || sent[0].GetError().GetId() != "stale" {
+		t.Errorf("sent %v, want an error for the stale document", sent)
+	}
+}
+
+// openStream starts srv on an in-memory connection and opens a v1 stream to it.
+func openStream(t *testing.T, srv *Server) pb.SreeificationService_SreeifyClient {
+	t.Helper()
+
+	conn := grpctest.Serve(t, func(s *grpc.Server) {
+		pb.RegisterSreeificationServiceServer(s, srv)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	stream, err := pb.NewSreeificationServiceClient(conn).Sreeify(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stream
+}
+
+func TestServerEvictsIdleStream(t *testing.T) {
+	srv := NewServer()
+	srv.staleAfter = 50 * time.Millisecond
+	stream := openStream(t, srv)
+
+	p := &pb.Payload{Id: "partial", Part: 0, TotalParts: 2, Data: []byte("<p>Wiki")}
+	if err := stream.Send(&pb.Sreequest{Data: &pb.Sreequest_Payload{Payload: p}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing else is sent, so only the ticker can evict the document.
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := resp.GetError(); e.GetId() != "partial" || e.GetCode() != pb.Error_INVALID_DOCUMENT {
+		t.Errorf("response = %v, want an INVALID_DOCUMENT error for partial", resp)
+	}
+}
+
+func TestServerBoundsConcurrency(t *testing.T) {
+	srv := NewServer()
+	srv.sem = make(chan struct{}, 1)
+	stream := openStream(t, srv)
+
+	// Take the only slot, as if another document were being sreeified.
+	srv.sem <- struct{}{}
+
+	p := &pb.Payload{Id: "doc", Part: 0, TotalParts: 1, Data: []byte("<p>Wiki</p>")}
+	if err := stream.Send(&pb.Sreequest{Data: &pb.Sreequest_Payload{Payload: p}}); err != nil {
+		t.Fatal(err)
+	}
+
+	resps := make(chan *pb.Sreesponse)
+	go func() {
+		defer close(resps)
+		for {
+			resp, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			resps <- resp
+		}
+	}()
+
+	select {
+	case resp := <-resps:
+		t.Fatalf("got %v while the server was busy, want nothing", resp)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	<-srv.sem
+	select {
+	case resp := <-resps:
+		if got := resp.GetPayload(); got.GetId() != "doc" || string(got.GetData()) != "<p>Sreeki</p>" {
+			t.Errorf("response = %v, want the sreeified doc", resp)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no response once the server was free")
+	}
+}
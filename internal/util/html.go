@@ -0,0 +1,140 @@
+package util
+
+import (
+	"bytes"
+	"io"
+
+	"golang.org/x/net/html"
+
+	"github.com/devhou-se/sreetcode/internal/ruleset"
+)
+
+// Elements whose contents are left untouched. Rewriting these would break scripts and styles, or
+// change metadata that isn't visible on the page. Code samples are kept as they are too, as are
+// form values, which would otherwise be submitted upstream sreefied. The ruleset can protect more
+// elements with selectors.
+var skippedElements = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"head":     true,
+	"title":    true,
+	"code":     true,
+	"pre":      true,
+	"textarea": true,
+	"option":   true,
+}
+
+// Elements that can appear in the head. The head end tag is optional, so any other element starting
+// closes it, as the body does.
+var headElements = map[string]bool{
+	"base": true, "basefont": true, "bgsound": true, "link": true, "meta": true, "noscript": true,
+	"script": true, "style": true, "template": true, "title": true,
+}
+
+// Elements that have no end tag, and so never contain text.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// SreefyHTML sreefies the text nodes of an HTML document, leaving markup, attribute values and
+// skipped elements byte-for-byte unchanged.
+func SreefyHTML(input []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(len(input))
+
+	if err := SreefyHTMLStream(&buf, bytes.NewReader(input)); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// SreefyHTMLStream reads an HTML document from r and writes the sreefied document to w as it is
+// tokenized, so the whole document never has to be held in memory.
+func SreefyHTMLStream(w io.Writer, r io.Reader) error {
+	// The whole document is sreefied with the same ruleset, even if it's reloaded meanwhile.
+	rs := ruleset.Current()
+	z := html.NewTokenizer(r)
+
+	// Open skipped and protected elements, counted per element name so that stray end tags can't
+	// unbalance each other. Once an element is open, nested elements of the same name are counted
+	// too, so that its own end tag can be found.
+	open := make(map[string]int)
+	skipping := func() bool {
+		for _, n := range open {
+			if n > 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if z.Err() == io.EOF {
+				// Anything left over is an unterminated tag, which is passed through as it is.
+				_, err := w.Write(z.Raw())
+				return err
+			}
+			return z.Err()
+		}
+
+		raw := z.Raw()
+
+		if tt == html.TextToken && !skipping() {
+			if _, err := io.WriteString(w, rs.Sreefy(string(raw))); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Raw must be written before TagName is called, as TagName lowercases the buffer in place.
+		if _, err := w.Write(raw); err != nil {
+			return err
+		}
+
+		switch tt {
+		case html.StartTagToken:
+			name, hasAttr := z.TagName()
+			tag := string(name)
+			if !headElements[tag] {
+				open["head"] = 0
+			}
+			switch tag {
+			case "option", "optgroup":
+				// So is the option end tag, and options don't nest, so the next one closes it.
+				open["option"] = 0
+			}
+			if voidElements[tag] {
+				continue
+			}
+			if open[tag] > 0 || skippedElements[tag] || rs.ProtectsElements() && rs.Protects(tag, attributes(z, hasAttr)) {
+				open[tag]++
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			tag := string(name)
+			switch tag {
+			case "select", "datalist", "optgroup":
+				// Ending the list closes an option left open.
+				open["option"] = 0
+			}
+			if open[tag] > 0 {
+				open[tag]--
+			}
+		}
+	}
+}
+
+// attributes returns the attributes of the current tag.
+func attributes(z *html.Tokenizer, more bool) []html.Attribute {
+	var attrs []html.Attribute
+	for more {
+		var key, val []byte
+		key, val, more = z.TagAttr()
+		attrs = append(attrs, html.Attribute{Key: string(key), Val: string(val)})
+	}
+	return attrs
+}
@@ -0,0 +1,112 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/devhou-se/sreetcode/internal/ruleset"
+)
+
+func TestSreefyHTMLSkippedElements(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`<p>Wikipedia</p>`, `<p>Sreekipedia</p>`},
+		{`<script>var Wiki = 1;</script>Wiki`, `<script>var Wiki = 1;</script>Sreeki`},
+		{`<style>.Wiki { color: red }</style>Wiki`, `<style>.Wiki { color: red }</style>Sreeki`},
+		{`<code>Wiki</code> <pre>Wiki</pre> Wiki`, `<code>Wiki</code> <pre>Wiki</pre> Sreeki`},
+		{`<svg><title>Wiki</title></svg>Wiki`, `<svg><title>Wiki</title></svg>Sreeki`},
+		{`<head><title>Wiki</title><body>Wiki`, `<head><title>Wiki</title><body>Sreeki`},
+		// Without a body tag, the first element that can't be in the head ends it.
+		{`<head><title>x</title><p>Wiki`, `<head><title>x</title><p>Sreeki`},
+		{`<head><meta charset="utf-8"><link rel="icon"><style>p {}</style><div>Wiki</div>`, `<head><meta charset="utf-8"><link rel="icon"><style>p {}</style><div>Sreeki</div>`},
+		// Form values are submitted upstream as they are.
+		{
+			`<textarea name="wpTextbox1">Wikipedia, the free encyclopedia</textarea><p>Wikipedia, the free encyclopedia`,
+			`<textarea name="wpTextbox1">Wikipedia, the free encyclopedia</textarea><p>Sreekipedia, the Sree encyclopedia`,
+		},
+		{`<textarea><b>Wiki</b></textarea>Wiki`, `<textarea><b>Wiki</b></textarea>Sreeki`},
+		{`<select><option>Wiki</option></select>Wiki`, `<select><option>Wiki</option></select>Sreeki`},
+		// Options without end tags are closed by the next option and by the end of the list.
+		{`<select><option>Wiki<option>Wiki</select>Wiki`, `<select><option>Wiki<option>Wiki</select>Sreeki`},
+		{
+			`<select><optgroup><option>Wiki</optgroup><optgroup label="Wiki"><option>Wiki</select>Wiki`,
+			`<select><optgroup><option>Wiki</optgroup><optgroup label="Wiki"><option>Wiki</select>Sreeki`,
+		},
+		{`<datalist><option>Wiki</datalist>Wiki`, `<datalist><option>Wiki</datalist>Sreeki`},
+		// Stray end tags don't end skipping early.
+		{`<pre></code>Wiki</pre>Wiki`, `<pre></code>Wiki</pre>Sreeki`},
+		{`<pre><pre>Wiki</pre>Wiki</pre>Wiki`, `<pre><pre>Wiki</pre>Wiki</pre>Sreeki`},
+	}
+	for _, tt := range tests {
+		got, err := SreefyHTML([]byte(tt.in))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("SreefyHTML(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSreefyHTMLProtectedElements(t *testing.T) {
+	rs, err := ruleset.Parse([]byte(`
+rules:
+  - match: Wiki
+    replace: Sreeki
+protect:
+  - selector: .keep
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := ruleset.Current()
+	ruleset.Set(rs)
+	defer ruleset.Set(prev)
+
+	tests := []struct {
+		in, want string
+	}{
+		{`<span class="keep">Wiki <b>Wiki</b></span> Wiki`, `<span class="keep">Wiki <b>Wiki</b></span> Sreeki`},
+		{`<div class="keep"><div>Wiki</div>Wiki</div>Wiki`, `<div class="keep"><div>Wiki</div>Wiki</div>Sreeki`},
+		// Elements whose end tag is left out aren't protected, so the rest of the page isn't
+		// either.
+		{`<ul><li class="keep">Wiki<li>Wiki</ul><p>Wiki`, `<ul><li class="keep">Sreeki<li>Sreeki</ul><p>Sreeki`},
+		{`<p class="keep">Wiki<div>Wiki</div>`, `<p class="keep">Sreeki<div>Sreeki</div>`},
+		{`<table><tr><td class="keep">Wiki<td>Wiki</table>Wiki`, `<table><tr><td class="keep">Sreeki<td>Sreeki</table>Sreeki`},
+	}
+	for _, tt := range tests {
+		got, err := SreefyHTML([]byte(tt.in))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("SreefyHTML(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSreefyHTMLDefaultProtections(t *testing.T) {
+	prev := ruleset.Current()
+	ruleset.Set(ruleset.Default())
+	defer ruleset.Set(prev)
+
+	tests := []struct {
+		in, want string
+	}{
+		{`<math><mi>Wiki</mi></math> Wiki`, `<math><mi>Wiki</mi></math> Sreeki`},
+		{`<kbd>Wiki</kbd><samp>Wiki</samp>`, `<kbd>Wiki</kbd><samp>Wiki</samp>`},
+		{`<span class="mwe-math-element">Wiki</span>Wiki`, `<span class="mwe-math-element">Wiki</span>Sreeki`},
+		{`<span class="Wiki" data-wiki="Wiki">Wiki</span>`, `<span class="Wiki" data-wiki="Wiki">Sreeki</span>`},
+		// Text that looks like an attribute is protected like one.
+		{`<p>Write class="Wiki" to style a Wiki.</p>`, `<p>Write class="Wiki" to style a Sreeki.</p>`},
+	}
+	for _, tt := range tests {
+		got, err := SreefyHTML([]byte(tt.in))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("SreefyHTML(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -1,22 +1,9 @@
 package util
 
 import (
-	"fmt"
-	"regexp"
-	"strings"
+	"github.com/devhou-se/sreetcode/internal/ruleset"
 )
 
-// A map containing words to replace and their corresponding replacements.
-var wordReplacements = map[string]string{
-	"Wiki":              "Sreeki",
-	"free encyclopedia": "Sree encyclopedia",
-	"Encyclopedia":      "Encyclosreedia",
-	"Free ":             "Sree ",
-	"Free_":             "Sree_",
-	"Free<":             "Sree<",
-	"Media":             "Sreedia",
-}
-
 var URLMappings = map[string]string{
 	"https://en.sreekinews.org/":    "/news/",
 	"https://en.sreekiquote.org/":   "/quote/",
@@ -28,57 +15,17 @@ var StaticFileOverrides = map[string]string{
 	"/static/images/mobile/copyright/sreekipedia-wordmark-en.svg": "sreekipedia.org/sreekipedia-wordmark-en.svg",
 	"/static/images/mobile/copyright/sreekipedia-tagline-en.svg":  "sreekipedia.org/tagling.svg",
 	"/static/favicon/sreekipedia.ico":                             "sreekipedia.org/sreeki.ico",
+	"/robots.txt":                                                 "sreekipedia.org/robots.txt",
 }
 
-// Unsreefy reverses the replacements made by the Sreefy function, restoring the original words.
+// Unsreefy reverses the replacements made by the Sreefy function, restoring the original words. The
+// original text is only restored exactly if the ruleset is reversible.
 func Unsreefy(input string) string {
-	// Replace each occurrence of the 'value' with its corresponding 'key'.
-	for original, replaced := range wordReplacements {
-		// Replace with respect to case variations (normal, lower, upper).
-		input = strings.ReplaceAll(input, replaced, original)
-		input = strings.ReplaceAll(input, strings.ToLower(replaced), strings.ToLower(original))
-		input = strings.ReplaceAll(input, strings.ToUpper(replaced), strings.ToUpper(original))
-	}
-
-	return input
+	return ruleset.Current().Unsreefy(input)
 }
 
-// Sreefy performs a set of replacements within the input string according to the wordReplacements map.
+// Sreefy performs the replacements of the current ruleset within the input string, leaving its
+// protected regions as they are.
 func Sreefy(input string) string {
-	// Regular expression to identify URLs to be temporarily removed from the replacement process.
-	urlPattern := regexp.MustCompile(`(//)((\w+)\.wikimedia.org)([\w\d+/_\-.%]*)["\s]`)
-	urlMatches := urlPattern.FindAllString(input, -1)
-
-	// Temporarily mask matched URLs using a placeholder.
-	for i, match := range urlMatches {
-		placeholder := fmt.Sprintf("{{%d}}", i)
-		input = strings.ReplaceAll(input, match, placeholder)
-	}
-
-	// Perform word replacements for different case variations (normal, lower, upper).
-	for original, replaced := range wordReplacements {
-		input = strings.ReplaceAll(input, original, replaced)
-		input = strings.ReplaceAll(input, strings.ToLower(original), strings.ToLower(replaced))
-		input = strings.ReplaceAll(input, strings.ToUpper(original), strings.ToUpper(replaced))
-	}
-
-	// Correct specific misreplacements.
-	input = strings.ReplaceAll(input, "matchSreedia", "matchMedia")
-	input = strings.ReplaceAll(input, "@sreedia", "@media")
-
-	// Restore the original URLs by replacing the placeholders.
-	for i, match := range urlMatches {
-		placeholder := fmt.Sprintf("{{%d}}", i)
-		input = strings.ReplaceAll(input, placeholder, match)
-	}
-
-	return input
-}
-
-func UpdateURLs(body string) string {
-	for original, replaced := range URLMappings {
-		body = strings.ReplaceAll(body, original, replaced)
-	}
-
-	return body
+	return ruleset.Current().Sreefy(input)
 }
@@ -14,7 +14,7 @@ _sym_db = _symbol_database.Default()
 
 
 
-DESCRIPTOR = _descriptor_pool.Default().AddSerializedFile(b'\n\rsreeify.proto\x12\x07sreeify\"b\n\x07Payload\x12\x0e\n\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n\x04part\x18\x02 \x01(\x05R\x04part\x12\x1f\n\x0btotal_parts\x18\x03 \x01(\x05R\ntotalParts\x12\x12\n\x04\x64\x61ta\x18\x04 \x01(\x0cR\x04\x64\x61ta\"\x1a\n\x04Ping\x12\x12\n\x04time\x18\x01 \x01(\x03R\x04time\"f\n\tSreequest\x12,\n\x07payload\x18\x01 \x01(\x0b\x32\x10.sreeify.PayloadH\x00R\x07payload\x12#\n\x04ping\x18\x02 \x01(\x0b\x32\r.sreeify.PingH\x00R\x04pingB\x06\n\x04\x64\x61ta\"g\n\nSreesponse\x12,\n\x07payload\x18\x01 \x01(\x0b\x32\x10.sreeify.PayloadH\x00R\x07payload\x12#\n\x04ping\x18\x02 \x01(\x0b\x32\r.sreeify.PingH\x00R\x04pingB\x06\n\x04\x64\x61ta2P\n\x14SreeificationService\x12\x38\n\x07Sreeify\x12\x12.sreeify.Sreequest\x1a\x13.sreeify.Sreesponse\"\x00(\x01\x30\x01\x42\x81\x01\n\x0b\x63om.sreeifyB\x0cSreeifyProtoP\x01Z(github.com/devhou-se/sreetcode/proto/gen\xa2\x02\x03SXX\xaa\x02\x07Sreeify\xca\x02\x07Sreeify\xe2\x02\x13Sreeify\\GPBMetadata\xea\x02\x07Sreeifyb\x06proto3')
+DESCRIPTOR = _descriptor_pool.Default().AddSerializedFile(b'\n\rsreeify.proto\x12\x07sreeify\"b\n\x07Payload\x12\x0e\n\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n\x04part\x18\x02 \x01(\x05R\x04part\x12\x1f\n\x0btotal_parts\x18\x03 \x01(\x05R\ntotalParts\x12\x12\n\x04\x64\x61ta\x18\x04 \x01(\x0cR\x04\x64\x61ta\"\x1a\n\x04Ping\x12\x12\n\x04time\x18\x01 \x01(\x03R\x04time\"\x9b\x01\n\x05\x45rror\x12\x0e\n\x02id\x18\x01 \x01(\tR\x02id\x12\'\n\x04\x63ode\x18\x02 \x01(\x0e\x32\x13.sreeify.Error.CodeR\x04\x63ode\x12 \n\x0b\x64\x65scription\x18\x03 \x01(\tR\x0b\x64\x65scription\"7\n\x04\x43ode\x12\x0b\n\x07UNKNOWN\x10\x00\x12\x14\n\x10INVALID_DOCUMENT\x10\x01\x12\x0c\n\x08INTERNAL\x10\x02\"f\n\tSreequest\x12,\n\x07payload\x18\x01 \x01(\x0b\x32\x10.sreeify.PayloadH\x00R\x07payload\x12#\n\x04ping\x18\x02 \x01(\x0b\x32\r.sreeify.PingH\x00R\x04pingB\x06\n\x04\x64\x61ta\"\x8f\x01\n\nSreesponse\x12,\n\x07payload\x18\x01 \x01(\x0b\x32\x10.sreeify.PayloadH\x00R\x07payload\x12#\n\x04ping\x18\x02 \x01(\x0b\x32\r.sreeify.PingH\x00R\x04ping\x12&\n\x05\x65rror\x18\x03 \x01(\x0b\x32\x0e.sreeify.ErrorH\x00R\x05\x65rrorB\x06\n\x04\x64\x61ta\"\x82\x01\n\x08Metadata\x12!\n\x0c\x63ontent_type\x18\x01 \x01(\tR\x0b\x63ontentType\x12\x18\n\x07\x63harset\x18\x02 \x01(\tR\x07\x63harset\x12\x1d\n\nsource_url\x18\x03 \x01(\tR\tsourceUrl\x12\x1a\n\x08language\x18\x04 \x01(\tR\x08language\"M\n\x08\x44ocument\x12-\n\x08metadata\x18\x01 \x01(\x0b\x32\x11.sreeify.MetadataR\x08metadata\x12\x12\n\x04\x64\x61ta\x18\x02 \x01(\x0cR\x04\x64\x61ta\"#\n\rDocumentChunk\x12\x12\n\x04\x64\x61ta\x18\x01 \x01(\x0cR\x04\x64\x61ta2P\n\x14SreeificationService\x12\x38\n\x07Sreeify\x12\x12.sreeify.Sreequest\x1a\x13.sreeify.Sreesponse\"\x00(\x01\x30\x01\x32\x9b\x01\n\x16SreeificationServiceV2\x12\x39\n\x0fSreeifyDocument\x12\x11.sreeify.Document\x1a\x11.sreeify.Document\"\x00\x12\x46\n\x15SreeifyDocumentStream\x12\x11.sreeify.Document\x1a\x16.sreeify.DocumentChunk\"\x00\x30\x01\x42\x81\x01\n\x0b\x63om.sreeifyB\x0cSreeifyProtoP\x01Z(github.com/devhou-se/sreetcode/proto/gen\xa2\x02\x03SXX\xaa\x02\x07Sreeify\xca\x02\x07Sreeify\xe2\x02\x13Sreeify\\GPBMetadata\xea\x02\x07Sreeifyb\x06proto3')
 
 _globals = globals()
 _builder.BuildMessageAndEnumDescriptors(DESCRIPTOR, _globals)
@@ -26,10 +26,22 @@ if _descriptor._USE_C_DESCRIPTORS == False:
   _globals['_PAYLOAD']._serialized_end=124
   _globals['_PING']._serialized_start=126
   _globals['_PING']._serialized_end=152
-  _globals['_SREEQUEST']._serialized_start=154
-  _globals['_SREEQUEST']._serialized_end=256
-  _globals['_SREESPONSE']._serialized_start=258
-  _globals['_SREESPONSE']._serialized_end=361
-  _globals['_SREEIFICATIONSERVICE']._serialized_start=363
-  _globals['_SREEIFICATIONSERVICE']._serialized_end=443
+  _globals['_ERROR']._serialized_start=155
+  _globals['_ERROR']._serialized_end=310
+  _globals['_ERROR_CODE']._serialized_start=255
+  _globals['_ERROR_CODE']._serialized_end=310
+  _globals['_SREEQUEST']._serialized_start=312
+  _globals['_SREEQUEST']._serialized_end=414
+  _globals['_SREESPONSE']._serialized_start=417
+  _globals['_SREESPONSE']._serialized_end=560
+  _globals['_METADATA']._serialized_start=563
+  _globals['_METADATA']._serialized_end=693
+  _globals['_DOCUMENT']._serialized_start=695
+  _globals['_DOCUMENT']._serialized_end=772
+  _globals['_DOCUMENTCHUNK']._serialized_start=774
+  _globals['_DOCUMENTCHUNK']._serialized_end=809
+  _globals['_SREEIFICATIONSERVICE']._serialized_start=811
+  _globals['_SREEIFICATIONSERVICE']._serialized_end=891
+  _globals['_SREEIFICATIONSERVICEV2']._serialized_start=894
+  _globals['_SREEIFICATIONSERVICEV2']._serialized_end=1049
 # @@protoc_insertion_point(module_scope)
@@ -4,10 +4,12 @@ isort:skip_file
 """
 import builtins
 import google.protobuf.descriptor
+import google.protobuf.internal.enum_type_wrapper
 import google.protobuf.message
 import sys
+import typing
 
-if sys.version_info >= (3, 8):
+if sys.version_info >= (3, 10):
     import typing as typing_extensions
 else:
     import typing_extensions
@@ -53,6 +55,42 @@ class Ping(google.protobuf.message.Message):
 
 global___Ping = Ping
 
+@typing_extensions.final
+class Error(google.protobuf.message.Message):
+    DESCRIPTOR: google.protobuf.descriptor.Descriptor
+
+    class _Code:
+        ValueType = typing.NewType("ValueType", builtins.int)
+        V: typing_extensions.TypeAlias = ValueType
+
+    class _CodeEnumTypeWrapper(google.protobuf.internal.enum_type_wrapper._EnumTypeWrapper[Error._Code.ValueType], builtins.type):  # noqa: F821
+        DESCRIPTOR: google.protobuf.descriptor.EnumDescriptor
+        UNKNOWN: Error._Code.ValueType  # 0
+        INVALID_DOCUMENT: Error._Code.ValueType  # 1
+        INTERNAL: Error._Code.ValueType  # 2
+
+    class Code(_Code, metaclass=_CodeEnumTypeWrapper): ...
+    UNKNOWN: Error.Code.ValueType  # 0
+    INVALID_DOCUMENT: Error.Code.ValueType  # 1
+    INTERNAL: Error.Code.ValueType  # 2
+
+    ID_FIELD_NUMBER: builtins.int
+    CODE_FIELD_NUMBER: builtins.int
+    DESCRIPTION_FIELD_NUMBER: builtins.int
+    id: builtins.str
+    code: global___Error.Code.ValueType
+    description: builtins.str
+    def __init__(
+        self,
+        *,
+        id: builtins.str = ...,
+        code: global___Error.Code.ValueType = ...,
+        description: builtins.str = ...,
+    ) -> None: ...
+    def ClearField(self, field_name: typing_extensions.Literal["code", b"code", "description", b"description", "id", b"id"]) -> None: ...
+
+global___Error = Error
+
 @typing_extensions.final
 class Sreequest(google.protobuf.message.Message):
     DESCRIPTOR: google.protobuf.descriptor.Descriptor
@@ -81,18 +119,81 @@ class Sreesponse(google.protobuf.message.Message):
 
     PAYLOAD_FIELD_NUMBER: builtins.int
     PING_FIELD_NUMBER: builtins.int
+    ERROR_FIELD_NUMBER: builtins.int
     @property
     def payload(self) -> global___Payload: ...
     @property
     def ping(self) -> global___Ping: ...
+    @property
+    def error(self) -> global___Error: ...
     def __init__(
         self,
         *,
         payload: global___Payload | None = ...,
         ping: global___Ping | None = ...,
+        error: global___Error | None = ...,
     ) -> None: ...
-    def HasField(self, field_name: typing_extensions.Literal["data", b"data", "payload", b"payload", "ping", b"ping"]) -> builtins.bool: ...
-    def ClearField(self, field_name: typing_extensions.Literal["data", b"data", "payload", b"payload", "ping", b"ping"]) -> None: ...
-    def WhichOneof(self, oneof_group: typing_extensions.Literal["data", b"data"]) -> typing_extensions.Literal["payload", "ping"] | None: ...
+    def HasField(self, field_name: typing_extensions.Literal["data", b"data", "error", b"error", "payload", b"payload", "ping", b"ping"]) -> builtins.bool: ...
+    def ClearField(self, field_name: typing_extensions.Literal["data", b"data", "error", b"error", "payload", b"payload", "ping", b"ping"]) -> None: ...
+    def WhichOneof(self, oneof_group: typing_extensions.Literal["data", b"data"]) -> typing_extensions.Literal["payload", "ping", "error"] | None: ...
 
 global___Sreesponse = Sreesponse
+
+@typing_extensions.final
+class Metadata(google.protobuf.message.Message):
+    DESCRIPTOR: google.protobuf.descriptor.Descriptor
+
+    CONTENT_TYPE_FIELD_NUMBER: builtins.int
+    CHARSET_FIELD_NUMBER: builtins.int
+    SOURCE_URL_FIELD_NUMBER: builtins.int
+    LANGUAGE_FIELD_NUMBER: builtins.int
+    content_type: builtins.str
+    charset: builtins.str
+    source_url: builtins.str
+    language: builtins.str
+    def __init__(
+        self,
+        *,
+        content_type: builtins.str = ...,
+        charset: builtins.str = ...,
+        source_url: builtins.str = ...,
+        language: builtins.str = ...,
+    ) -> None: ...
+    def ClearField(self, field_name: typing_extensions.Literal["charset", b"charset", "content_type", b"content_type", "language", b"language", "source_url", b"source_url"]) -> None: ...
+
+global___Metadata = Metadata
+
+@typing_extensions.final
+class Document(google.protobuf.message.Message):
+    DESCRIPTOR: google.protobuf.descriptor.Descriptor
+
+    METADATA_FIELD_NUMBER: builtins.int
+    DATA_FIELD_NUMBER: builtins.int
+    @property
+    def metadata(self) -> global___Metadata: ...
+    data: builtins.bytes
+    def __init__(
+        self,
+        *,
+        metadata: global___Metadata | None = ...,
+        data: builtins.bytes = ...,
+    ) -> None: ...
+    def HasField(self, field_name: typing_extensions.Literal["metadata", b"metadata"]) -> builtins.bool: ...
+    def ClearField(self, field_name: typing_extensions.Literal["data", b"data", "metadata", b"metadata"]) -> None: ...
+
+global___Document = Document
+
+@typing_extensions.final
+class DocumentChunk(google.protobuf.message.Message):
+    DESCRIPTOR: google.protobuf.descriptor.Descriptor
+
+    DATA_FIELD_NUMBER: builtins.int
+    data: builtins.bytes
+    def __init__(
+        self,
+        *,
+        data: builtins.bytes = ...,
+    ) -> None: ...
+    def ClearField(self, field_name: typing_extensions.Literal["data", b"data"]) -> None: ...
+
+global___DocumentChunk = DocumentChunk
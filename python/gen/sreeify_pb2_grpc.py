@@ -64,3 +64,97 @@ class SreeificationService(object):
             sreeify__pb2.Sreesponse.FromString,
             options, channel_credentials,
             insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+
+class SreeificationServiceV2Stub(object):
+    """Missing associated documentation comment in .proto file."""
+
+    def __init__(self, channel):
+        """Constructor.
+
+        Args:
+            channel: A grpc.Channel.
+        """
+        self.SreeifyDocument = channel.unary_unary(
+                '/sreeify.SreeificationServiceV2/SreeifyDocument',
+                request_serializer=sreeify__pb2.Document.SerializeToString,
+                response_deserializer=sreeify__pb2.Document.FromString,
+                )
+        self.SreeifyDocumentStream = channel.unary_stream(
+                '/sreeify.SreeificationServiceV2/SreeifyDocumentStream',
+                request_serializer=sreeify__pb2.Document.SerializeToString,
+                response_deserializer=sreeify__pb2.DocumentChunk.FromString,
+                )
+
+
+class SreeificationServiceV2Servicer(object):
+    """Missing associated documentation comment in .proto file."""
+
+    def SreeifyDocument(self, request, context):
+        """Missing associated documentation comment in .proto file."""
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def SreeifyDocumentStream(self, request, context):
+        """Missing associated documentation comment in .proto file."""
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+
+def add_SreeificationServiceV2Servicer_to_server(servicer, server):
+    rpc_method_handlers = {
+            'SreeifyDocument': grpc.unary_unary_rpc_method_handler(
+                    servicer.SreeifyDocument,
+                    request_deserializer=sreeify__pb2.Document.FromString,
+                    response_serializer=sreeify__pb2.Document.SerializeToString,
+            ),
+            'SreeifyDocumentStream': grpc.unary_stream_rpc_method_handler(
+                    servicer.SreeifyDocumentStream,
+                    request_deserializer=sreeify__pb2.Document.FromString,
+                    response_serializer=sreeify__pb2.DocumentChunk.SerializeToString,
+            ),
+    }
+    generic_handler = grpc.method_handlers_generic_handler(
+            'sreeify.SreeificationServiceV2', rpc_method_handlers)
+    server.add_generic_rpc_handlers((generic_handler,))
+
+
+ # This class is part of an EXPERIMENTAL API.
+class SreeificationServiceV2(object):
+    """Missing associated documentation comment in .proto file."""
+
+    @staticmethod
+    def SreeifyDocument(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/sreeify.SreeificationServiceV2/SreeifyDocument',
+            sreeify__pb2.Document.SerializeToString,
+            sreeify__pb2.Document.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def SreeifyDocumentStream(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_stream(request, target, '/sreeify.SreeificationServiceV2/SreeifyDocumentStream',
+            sreeify__pb2.Document.SerializeToString,
+            sreeify__pb2.DocumentChunk.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
@@ -26,9 +26,17 @@ class SreeificationService(sreeify_pb2_grpc.SreeificationService):
 
             if all([datum is not None for datum in data[payload.id]]):
                 flat_bytes = b"".join(data[payload.id])
-                resp_data = flat_bytes.decode(ENCODING)
                 logging.info(f"Received request {payload.id} with {len(data[payload.id])} parts and {len(flat_bytes)} bytes")
-                resp = sreeify_text(resp_data)
+                try:
+                    resp = sreeify_text(flat_bytes.decode(ENCODING))
+                except Exception as e:
+                    logging.exception(f"Error sreeifying request {payload.id}")
+                    code = sreeify_pb2.Error.INVALID_DOCUMENT if isinstance(e, UnicodeDecodeError) else sreeify_pb2.Error.INTERNAL
+                    yield sreeify_pb2.Sreesponse(
+                        error=sreeify_pb2.Error(id=payload.id, code=code, description=str(e))
+                    )
+                    del data[payload.id]
+                    continue
                 chunks = [resp[i:i + CHUNK_SIZE] for i in range(0, len(resp), CHUNK_SIZE)]
                 for i, chunk in enumerate(chunks):
                     yield sreeify_pb2.Sreesponse(